@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+var batchCommand = command{
+	name:    "batch",
+	args:    "<tileset dir>",
+	summary: "generate many maps in parallel with a manifest",
+	help: "Generates -count maps on a pool of -workers, writing each to -out as map-<n>.<format> for every -formats entry.\n" +
+		"Seeds count up from -seed, or are random if it's 0. manifest.json in -out lists the seed, duration, steps,\n" +
+		"backtracks and any failure of every map. Exits with status 1 if any map fails.",
+	run: runBatch,
+}
+
+// Manifest summarises a batch, written to manifest.json next to the maps
+type Manifest struct {
+	Tileset    string        `json:"tileset"`
+	Width      int           `json:"width"`
+	Height     int           `json:"height"`
+	Count      int           `json:"count"`
+	Failed     int           `json:"failed"`
+	Workers    int           `json:"workers"`
+	DurationMs float64       `json:"durationMs"` // wall-clock time of the whole batch
+	Maps       []BatchResult `json:"maps"`
+}
+
+// BatchResult describes the generation of a single map in a batch
+type BatchResult struct {
+	Index      int      `json:"index"`
+	Seed       int64    `json:"seed"`
+	Files      []string `json:"files,omitempty"` // written relative to the manifest, only for maps that succeeded
+	DurationMs float64  `json:"durationMs"`
+	Steps      int      `json:"steps"`
+	Backtracks int      `json:"backtracks"`
+	Reason     string   `json:"reason,omitempty"` // why generation stopped, e.g. step limit reached
+	Error      string   `json:"error,omitempty"`
+}
+
+func runBatch(flags *flag.FlagSet, args []string) int {
+	width := flags.Int("width", 0, "width of grid to collapse, defaults to the tileset's width or 32")
+	height := flags.Int("height", 0, "height of grid to collapse, defaults to the tileset's height or 18")
+	count := flags.Int("count", 10, "number of maps to generate")
+	seed := flags.Int64("seed", 0, "seed of the first map, each map after uses the next seed, 0 for random seeds")
+	workers := flags.Int("workers", runtime.NumCPU(), "maps to generate at once")
+	out := flags.String("out", "batch", "directory to write the maps and manifest to")
+	formats := flags.String("formats", "png,json", "comma separated formats to write each map in: png, json, csv, bin, tmx or ldtk")
+	tileSize := flags.Int("tilesize", 0, "pixel size of each tile in a PNG, defaults to the tileset's tile size or the tile image size")
+	gen := generationFlags{}
+	flags.DurationVar(&gen.timeout, "timeout", time.Minute, "give up a map after this long, 0 for no limit")
+	flags.IntVar(&gen.maxSteps, "max-steps", 0, "give up a map after this many steps, 0 for no limit")
+	flags.IntVar(&gen.maxBacktracks, "max-backtracks", 0, "give up a map after this many backtracks, 0 for no limit")
+	flags.IntVar(&gen.attempts, "attempts", 1, "attempts to run at once for each map with different seeds, the first to succeed is kept")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	if *count <= 0 || *workers <= 0 {
+		fmt.Fprintf(flags.Output(), "-count and -workers must be positive, got %d and %d\n", *count, *workers)
+		return exitUsage
+	}
+
+	var extensions []string
+	for _, format := range strings.Split(*formats, ",") {
+		ext := "." + strings.ToLower(strings.TrimSpace(format))
+		if !isOutputFormat(ext) {
+			fmt.Fprintf(flags.Output(), "unknown format %q in -formats, expected png, json, csv, bin, tmx or ldtk\n", format)
+			return exitUsage
+		}
+		extensions = append(extensions, ext)
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	gridWidth, gridHeight := gridSize(ts.Settings, *width, *height)
+	if err := checkGrid(ts, gridWidth, gridHeight, nil); err != nil {
+		return fail(err)
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return fail(err)
+	}
+
+	seeds := batchSeeds(*seed, *count)
+	writer := newResultWriter(ts, *tileSize)
+	for _, ext := range extensions {
+		if err := writer.prepare(ext); err != nil {
+			return fail(err)
+		}
+	}
+	tiles := ts.WfcTiles()
+
+	generate := func(index int) BatchResult {
+		opts := gen.options()
+		opts.Seed = seeds[index]
+		result := BatchResult{Index: index, Seed: seeds[index]}
+
+		start := time.Now()
+		solver, res, err := wfc.Solve(context.Background(), tiles, gridWidth, gridHeight, opts)
+		result.DurationMs = milliseconds(time.Since(start))
+		// With several attempts the map's seed is the winning attempt's
+		result.Seed = solver.Seed()
+		result.Steps, result.Backtracks = solver.Steps(), solver.Backtracks()
+
+		if err != nil {
+			result.Error = err.Error()
+			var stopErr *wfc.StopError
+			if errors.As(err, &stopErr) {
+				result.Reason = stopErr.Reason.Error()
+			}
+			return result
+		}
+
+		for _, ext := range extensions {
+			name := fmt.Sprintf("map-%d%s", index, ext)
+			if err := writer.write(path.Join(*out, name), res, result.Seed); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+			result.Files = append(result.Files, name)
+		}
+		return result
+	}
+
+	start := time.Now()
+	manifest := Manifest{
+		Tileset: ts.Dir(),
+		Width:   gridWidth,
+		Height:  gridHeight,
+		Count:   *count,
+		Workers: *workers,
+		Maps:    make([]BatchResult, *count),
+	}
+
+	for result := range runPool(*count, *workers, generate) {
+		manifest.Maps[result.Index] = result
+		if result.Error != "" {
+			manifest.Failed++
+			fmt.Printf("map %d seed %d failed after %.0fms: %s\n", result.Index, result.Seed, result.DurationMs, result.Error)
+			continue
+		}
+		fmt.Printf("map %d seed %d done in %.0fms, %d backtracks\n", result.Index, result.Seed, result.DurationMs, result.Backtracks)
+	}
+	manifest.DurationMs = milliseconds(time.Since(start))
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fail(err)
+	}
+	manifestPath := path.Join(*out, "manifest.json")
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		return fail(fmt.Errorf("failed to write %s with error %v", manifestPath, err))
+	}
+
+	fmt.Printf("%d maps of %dx%d in %v, %d failed, manifest written to %s\n",
+		*count, gridWidth, gridHeight, time.Since(start).Round(time.Millisecond), manifest.Failed, manifestPath)
+	if manifest.Failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// Returns a seed for each map, counting up from first, or random if first is 0
+func batchSeeds(first int64, count int) []int64 {
+	seeds := make([]int64, count)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for idx := range seeds {
+		if first != 0 {
+			seeds[idx] = first + int64(idx)
+			continue
+		}
+
+		// 0 asks the solver for a random seed, so it can't be recorded
+		for seeds[idx] == 0 {
+			seeds[idx] = rng.Int63()
+		}
+	}
+	return seeds
+}
+
+// Runs job for every index up to count on a pool of workers, sending each result as it finishes
+// The channel is closed once every job is done
+func runPool(count, workers int, job func(index int) BatchResult) <-chan BatchResult {
+	indexes := make(chan int)
+	results := make(chan BatchResult)
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < workers && worker < count; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results <- job(index)
+			}
+		}()
+	}
+
+	go func() {
+		for index := 0; index < count; index++ {
+			indexes <- index
+		}
+		close(indexes)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
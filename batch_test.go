@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_batchSeeds(t *testing.T) {
+	if seeds := batchSeeds(5, 3); !reflect.DeepEqual(seeds, []int64{5, 6, 7}) {
+		t.Errorf("Failed, expected sequential seeds from 5, got %v", seeds)
+	}
+
+	for _, seed := range batchSeeds(0, 10) {
+		if seed == 0 {
+			t.Errorf("Failed, expected random seeds to be set")
+		}
+	}
+}
+
+func Test_runPool(t *testing.T) {
+	var indexes []int
+	for result := range runPool(20, 3, func(index int) BatchResult { return BatchResult{Index: index} }) {
+		indexes = append(indexes, result.Index)
+	}
+
+	sort.Ints(indexes)
+	for idx := range indexes {
+		if indexes[idx] != idx {
+			t.Fatalf("Failed, expected every index once, got %v", indexes)
+		}
+	}
+}
+
+func Test_runBatch(t *testing.T) {
+	out := t.TempDir()
+	args := []string{"-count=4", "-workers=2", "-seed=9", "-width=6", "-height=4", "-formats=json,csv", "-out=" + out, "assets"}
+	if code := runBatch(newFlagSet(batchCommand), args); code != exitOK {
+		t.Fatalf("Failed, expected exit code %v, got %v", exitOK, code)
+	}
+
+	data, err := os.ReadFile(path.Join(out, "manifest.json"))
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if manifest.Count != 4 || manifest.Failed != 0 || len(manifest.Maps) != 4 {
+		t.Fatalf("Failed, expected 4 maps without failures, got %+v", manifest)
+	}
+	for idx, result := range manifest.Maps {
+		if result.Index != idx || result.Seed != int64(9+idx) || len(result.Files) != 2 {
+			t.Errorf("Failed, expected map %v with seed %v and two files, got %+v", idx, 9+idx, result)
+		}
+		for _, file := range result.Files {
+			if _, err := os.Stat(path.Join(out, file)); err != nil {
+				t.Errorf("Failed, expected %v to be written, got %v", file, err)
+			}
+		}
+	}
+}
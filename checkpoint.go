@@ -0,0 +1,77 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"wavefunctioncollapse/wfc"
+)
+
+// Writes a solver's state to a checkpoint, gzipped if the file ends in .gz
+// The checkpoint is written next to the file and renamed over it, so a crash while writing leaves the last one intact
+func writeSnapshot(filePath string, snapshot *wfc.Snapshot) error {
+	tmpPath := filePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint %s with error %v", tmpPath, err)
+	}
+	defer os.Remove(tmpPath)
+
+	err = encodeSnapshot(file, snapshot, isGzip(filePath))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint %s with error %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace checkpoint %s with error %v", filePath, err)
+	}
+	return nil
+}
+
+func encodeSnapshot(w io.Writer, snapshot *wfc.Snapshot, compress bool) error {
+	if !compress {
+		return json.NewEncoder(w).Encode(snapshot)
+	}
+
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(snapshot); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Reads a checkpoint written by writeSnapshot
+func readSnapshot(filePath string) (*wfc.Snapshot, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint %s with error %v", filePath, err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if isGzip(filePath) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint %s with error %v", filePath, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var snapshot wfc.Snapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint %s with error %v", filePath, err)
+	}
+	return &snapshot, nil
+}
+
+func isGzip(filePath string) bool {
+	return strings.ToLower(path.Ext(filePath)) == ".gz"
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+func Test_writeSnapshot(t *testing.T) {
+	ts, err := tileset.Load("assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	solver := wfc.NewSolver(ts.WfcTiles(), 5, 4, wfc.Options{Seed: 2})
+	for step := 0; step < 6; step++ {
+		solver.Step()
+	}
+	snapshot := solver.Snapshot()
+
+	for _, name := range []string{"checkpoint.json", "checkpoint.json.gz"} {
+		filePath := filepath.Join(t.TempDir(), name)
+		if err := writeSnapshot(filePath, snapshot); err != nil {
+			t.Fatalf("Failed, expected no error writing %s, got %v", name, err)
+		}
+
+		read, err := readSnapshot(filePath)
+		if err != nil || !reflect.DeepEqual(snapshot.Domains, read.Domains) || read.Draws != snapshot.Draws {
+			t.Errorf("Failed, expected %s to read back the same, got %+v with err %v", name, read, err)
+		}
+
+		if _, err := resumeSolver(ts, filePath, wfc.Options{}); err != nil {
+			t.Errorf("Failed, expected to resume %s with its tileset, got %v", name, err)
+		}
+	}
+}
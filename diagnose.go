@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+	"wavefunctioncollapse/diagnostics"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/disintegration/imaging"
+)
+
+var diagnoseCommand = command{
+	name:    "diagnose",
+	args:    "<tileset dir>",
+	summary: "report every contradiction hit while generating",
+	help: "Generates a map recording every contradiction: the position, its neighbours' tiles, the connectors that failed\n" +
+		"to match and the decisions leading there. Writes a summary of the missing connectors and the contradictions to\n" +
+		"-report, as JSON if it ends in .json, and the map with the trouble spots highlighted to -overlay.\n" +
+		"-resume reproduces the contradictions after a checkpoint. Exits with status 1 if generation fails.",
+	run: runDiagnose,
+}
+
+func runDiagnose(flags *flag.FlagSet, args []string) int {
+	width := flags.Int("width", 0, "width of grid to collapse, defaults to the tileset's width or 32")
+	height := flags.Int("height", 0, "height of grid to collapse, defaults to the tileset's height or 18")
+	seed := flags.Int64("seed", 0, "seed for the generation, 0 for a random seed")
+	resume := flags.String("resume", "", "checkpoint to carry on generating from, its grid size and seed are used")
+	reportPath := flags.String("report", "diagnostics.txt", "file to write the report to, JSON if it ends in .json")
+	overlayPath := flags.String("overlay", "diagnostics.png", "PNG to draw the map and its contradictions to, empty for none")
+	tileSize := flags.Int("tilesize", 0, "pixel size of each tile in the overlay, defaults to the tileset's tile size or the tile image size")
+	gen := generationFlags{}
+	flags.DurationVar(&gen.timeout, "timeout", time.Minute, "give up generating after this long, 0 for no limit")
+	flags.IntVar(&gen.maxSteps, "max-steps", 0, "give up generating after this many steps, 0 for no limit")
+	flags.IntVar(&gen.maxBacktracks, "max-backtracks", 0, "give up generating after this many backtracks, 0 for no limit")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	if *resume != "" && *seed != 0 {
+		fmt.Fprintf(flags.Output(), "-resume can't be used with -seed, the checkpoint has its own\n")
+		return exitUsage
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	opts := gen.options()
+	opts.Seed = *seed
+	opts.Diagnose = true
+	solver, err := resumeSolver(ts, *resume, opts)
+	if err != nil {
+		return fail(err)
+	}
+	if solver == nil {
+		gridWidth, gridHeight := gridSize(ts.Settings, *width, *height)
+		if err := checkGrid(ts, gridWidth, gridHeight, nil); err != nil {
+			return fail(err)
+		}
+		solver = wfc.NewSolver(ts.WfcTiles(), gridWidth, gridHeight, opts)
+	}
+	res, genErr := solver.Run(context.Background())
+
+	report := diagnostics.New(ts, solver, genErr)
+	var buf bytes.Buffer
+	if err := report.Write(&buf, *reportPath); err != nil {
+		return fail(err)
+	}
+	if err := os.WriteFile(*reportPath, buf.Bytes(), 0644); err != nil {
+		return fail(fmt.Errorf("failed to write %s with error %v", *reportPath, err))
+	}
+
+	if *overlayPath != "" {
+		if *tileSize <= 0 {
+			*tileSize = ts.Settings.TileSize
+		}
+		img, err := diagnostics.Overlay(ts, res, report, *tileSize)
+		if err != nil {
+			return fail(err)
+		}
+		if err := imaging.Save(img, *overlayPath); err != nil {
+			return fail(fmt.Errorf("failed to save image %s with error %v", *overlayPath, err))
+		}
+	}
+
+	fmt.Printf("%d contradictions at %d positions, report written to %s\n", len(report.Contradictions), len(report.Hotspots), *reportPath)
+	if genErr != nil {
+		return fail(fmt.Errorf("%v, seed %d", genErr, solver.Seed()))
+	}
+	return exitOK
+}
@@ -0,0 +1,77 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+func Test_New(t *testing.T) {
+	// No tile can sit next to another horizontally, as nothing has "BBB" on its left
+	ts := tileset.New("mismatch", []tileset.Tile{
+		{Name: "a.png", Connections: tileset.Connections{wfc.LEFT: "AAA", wfc.UP: "CCC", wfc.RIGHT: "BBB", wfc.DOWN: "CCC"}},
+		{Name: "b.png", Connections: tileset.Connections{wfc.LEFT: "AAA", wfc.UP: "CCC", wfc.RIGHT: "BBB", wfc.DOWN: "CCC"}},
+	})
+
+	solver := wfc.NewSolver(ts.WfcTiles(), 2, 1, wfc.Options{Seed: 1, Diagnose: true})
+	_, err := solver.Run(context.Background())
+	report := New(ts, solver, err)
+	if report.Error == "" || len(report.Contradictions) != 1 || len(report.Hotspots) != 1 {
+		t.Fatalf("Failed, expected a failed generation with one contradiction, got %+v", report)
+	}
+
+	// Tiles fail towards whichever end the solver didn't start at
+	expected := Missing{Side: "left", Connector: "BBB", Count: 2, InTileset: false, Tiles: []int{0, 1}}
+	if report.Hotspots[0].X == 1 {
+		expected = Missing{Side: "right", Connector: "AAA", Count: 2, InTileset: false, Tiles: []int{0, 1}}
+	}
+	if len(report.Missing) != 1 || !reflect.DeepEqual(expected, report.Missing[0]) {
+		t.Errorf("Failed, expected missing %+v, got %+v", expected, report.Missing)
+	}
+
+	var text strings.Builder
+	if err := report.Write(&text, "report.txt"); err != nil || !strings.Contains(text.String(), "no tile has it, needed by a.png, b.png") {
+		t.Errorf("Failed, expected the text report to name the missing connector, got %s with err %v", text.String(), err)
+	}
+
+	var data strings.Builder
+	var decoded Report
+	if err := report.Write(&data, "report.json"); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	if err := json.Unmarshal([]byte(data.String()), &decoded); err != nil || !reflect.DeepEqual(report.Missing, decoded.Missing) {
+		t.Errorf("Failed, expected the JSON report to decode to the same, got %+v with err %v", decoded, err)
+	}
+}
+
+func Test_Overlay(t *testing.T) {
+	ts, err := tileset.Load("../assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	report := &Report{
+		Hotspots:       []Hotspot{{X: 1, Y: 0, Count: 2}},
+		Contradictions: []wfc.Contradiction{{X: 1, Y: 0}},
+	}
+	result := [][]int{{0, 0}, {wfc.Undecided, 0}}
+	img, err := Overlay(ts, result, report, 10)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if img.Bounds().Dx() != 20 || img.NRGBAAt(10, 0) != latestColour {
+		t.Errorf("Failed, expected a 20 pixel image with the latest contradiction outlined, got %v and %v", img.Bounds(), img.NRGBAAt(10, 0))
+	}
+	if inside := img.NRGBAAt(15, 5); inside.R != hotspotColour.R || inside.A != maxTint {
+		t.Errorf("Failed, expected the undecided hotspot tinted fully, got %v", inside)
+	}
+	if outside := img.NRGBAAt(15, 15); outside == (color.NRGBA{R: 255, G: 32, B: 32, A: maxTint}) {
+		t.Errorf("Failed, expected positions without contradictions left alone, got %v", outside)
+	}
+}
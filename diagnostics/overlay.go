@@ -0,0 +1,69 @@
+package diagnostics
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"wavefunctioncollapse/render"
+	"wavefunctioncollapse/tileset"
+)
+
+// Colours of the overlay, positions with more contradictions are tinted more strongly
+var (
+	hotspotColour = color.NRGBA{R: 255, G: 32, B: 32}
+	latestColour  = color.NRGBA{R: 255, G: 220, B: 0, A: 255}
+)
+
+// Lightest and strongest tint of a hotspot, out of 255
+const (
+	minTint = 64
+	maxTint = 200
+)
+
+// Renders a result with the report's hotspots tinted red over it, and the latest contradiction outlined in yellow
+// A tileSize of 0 uses the tile image size, undecided positions are left transparent under the tint
+func Overlay(ts *tileset.Tileset, result [][]int, report *Report, tileSize int) (*image.NRGBA, error) {
+	images, err := render.LoadImages(ts)
+	if err != nil {
+		return nil, err
+	}
+	img, err := render.Render(result, images, tileSize)
+	if err != nil {
+		return nil, err
+	}
+	tileSize = img.Bounds().Dx() / len(result)
+
+	most := 0
+	for _, hotspot := range report.Hotspots {
+		if hotspot.Count > most {
+			most = hotspot.Count
+		}
+	}
+	for _, hotspot := range report.Hotspots {
+		tint := hotspotColour
+		tint.A = uint8(minTint + (maxTint-minTint)*hotspot.Count/most)
+		rect := image.Rect(hotspot.X*tileSize, hotspot.Y*tileSize, (hotspot.X+1)*tileSize, (hotspot.Y+1)*tileSize)
+		draw.Draw(img, rect, image.NewUniform(tint), image.Point{}, draw.Over)
+	}
+
+	if len(report.Contradictions) > 0 {
+		latest := report.Contradictions[len(report.Contradictions)-1]
+		outline(img, image.Rect(latest.X*tileSize, latest.Y*tileSize, (latest.X+1)*tileSize, (latest.Y+1)*tileSize), latestColour)
+	}
+
+	return img, nil
+}
+
+// Draws the border of a rectangle, a tenth of its width thick
+func outline(img draw.Image, rect image.Rectangle, colour color.Color) {
+	thickness := rect.Dx() / 10
+	if thickness < 1 {
+		thickness = 1
+	}
+
+	fill := image.NewUniform(colour)
+	draw.Draw(img, image.Rect(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Min.Y+thickness), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(rect.Min.X, rect.Max.Y-thickness, rect.Max.X, rect.Max.Y), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+thickness, rect.Max.Y), fill, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(rect.Max.X-thickness, rect.Min.Y, rect.Max.X, rect.Max.Y), fill, image.Point{}, draw.Src)
+}
@@ -0,0 +1,263 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Most contradictions and hotspots described in a text report, the JSON report has them all
+const (
+	textContradictions = 10
+	textHotspots       = 10
+)
+
+// Report summarises the contradictions of a generation, for tileset authors to see which tiles are missing
+type Report struct {
+	Tileset        string              `json:"tileset"`
+	Width          int                 `json:"width"`
+	Height         int                 `json:"height"`
+	Seed           int64               `json:"seed"`
+	Steps          int                 `json:"steps"`
+	Backtracks     int                 `json:"backtracks"`
+	Error          string              `json:"error,omitempty"` // why generation stopped, empty if it succeeded
+	Tiles          []string            `json:"tiles"`           // label of each tile by ID
+	Missing        []Missing           `json:"missing"`         // connectors no neighbour could offer, most often first
+	Hotspots       []Hotspot           `json:"hotspots"`        // positions with contradictions, most first
+	Contradictions []wfc.Contradiction `json:"contradictions"`
+}
+
+// Missing is a connector a neighbour needed on one side and didn't have
+// If no tile in the tileset has it, adding one that does may remove the contradictions
+type Missing struct {
+	Side      string `json:"side"` // side of the neighbour that needed the connector
+	Connector string `json:"connector"`
+	Count     int    `json:"count"`     // conflicts that needed it
+	InTileset bool   `json:"inTileset"` // whether any tile has the connector on that side
+	Tiles     []int  `json:"tiles"`     // IDs of the tiles that needed it, sorted
+}
+
+// Hotspot is a position where the solver ran into contradictions
+type Hotspot struct {
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	Count int `json:"count"`
+}
+
+// Returns a report of the contradictions the solver recorded, err is the error that stopped generation if any
+// The solver needs Options.Diagnose set to have recorded anything
+func New(ts *tileset.Tileset, solver *wfc.Solver, err error) *Report {
+	width, height := solver.Size()
+	report := &Report{
+		Tileset:        ts.Dir(),
+		Width:          width,
+		Height:         height,
+		Seed:           solver.Seed(),
+		Steps:          solver.Steps(),
+		Backtracks:     solver.Backtracks(),
+		Tiles:          make([]string, len(ts.Tiles)),
+		Missing:        []Missing{},
+		Hotspots:       []Hotspot{},
+		Contradictions: solver.Contradictions(),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if report.Contradictions == nil {
+		report.Contradictions = []wfc.Contradiction{}
+	}
+	for id, tile := range ts.Tiles {
+		report.Tiles[id] = tile.Label()
+	}
+
+	// Connectors every tile offers on each side, to tell missing tiles from ones ruled out by other neighbours
+	offered := make(map[int]map[string]bool, 4)
+	for dir := range tileset.DirectionNames {
+		offered[dir] = make(map[string]bool)
+	}
+	for _, tile := range ts.Tiles {
+		for dir, connector := range tile.Connections {
+			offered[dir][connector] = true
+		}
+	}
+
+	type missingKey struct {
+		side      int
+		connector string
+	}
+	missing := make(map[missingKey]*Missing)
+	hotspots := make(map[[2]int]int)
+	for _, contradiction := range report.Contradictions {
+		hotspots[[2]int{contradiction.X, contradiction.Y}]++
+		for _, conflict := range contradiction.Conflicts {
+			key := missingKey{(conflict.Direction + 2) % 4, conflict.Needed}
+			entry, ok := missing[key]
+			if !ok {
+				entry = &Missing{Side: tileset.DirectionNames[key.side], Connector: key.connector, InTileset: offered[key.side][key.connector]}
+				missing[key] = entry
+			}
+			entry.Count++
+			if !containsId(entry.Tiles, conflict.TileId) {
+				entry.Tiles = append(entry.Tiles, conflict.TileId)
+			}
+		}
+	}
+
+	for _, entry := range missing {
+		sort.Ints(entry.Tiles)
+		report.Missing = append(report.Missing, *entry)
+	}
+	sort.Slice(report.Missing, func(i, j int) bool {
+		a, b := report.Missing[i], report.Missing[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Side != b.Side {
+			return a.Side < b.Side
+		}
+		return a.Connector < b.Connector
+	})
+
+	for pos, count := range hotspots {
+		report.Hotspots = append(report.Hotspots, Hotspot{X: pos[0], Y: pos[1], Count: count})
+	}
+	sort.Slice(report.Hotspots, func(i, j int) bool {
+		a, b := report.Hotspots[i], report.Hotspots[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+
+	return report
+}
+
+// Writes the report as JSON if the file name ends in .json, otherwise as text
+func (report *Report) Write(w io.Writer, name string) error {
+	if strings.ToLower(path.Ext(name)) == ".json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(w)
+}
+
+// Writes a summary for people, the missing connectors and hotspots, then the latest contradictions in detail
+func (report *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	outcome := "succeeded"
+	if report.Error != "" {
+		outcome = "failed: " + report.Error
+	}
+	fmt.Fprintf(&b, "%s, %dx%d seed %d, %d steps and %d backtracks, %s\n", report.Tileset, report.Width, report.Height,
+		report.Seed, report.Steps, report.Backtracks, outcome)
+	fmt.Fprintf(&b, "%d contradictions at %d positions\n", len(report.Contradictions), len(report.Hotspots))
+
+	if len(report.Missing) > 0 {
+		fmt.Fprintf(&b, "\nconnectors neighbours needed and didn't have:\n")
+		for _, missing := range report.Missing {
+			note := "no tile has it"
+			if missing.InTileset {
+				note = "ruled out by other neighbours"
+			}
+			fmt.Fprintf(&b, "  %5d  %s %q, %s, needed by %s\n", missing.Count, missing.Side, missing.Connector, note, report.labels(missing.Tiles))
+		}
+	}
+
+	if len(report.Hotspots) > 0 {
+		fmt.Fprintf(&b, "\npositions with the most contradictions:\n")
+		for idx, hotspot := range report.Hotspots {
+			if idx == textHotspots {
+				fmt.Fprintf(&b, "  and %d more\n", len(report.Hotspots)-idx)
+				break
+			}
+			fmt.Fprintf(&b, "  %5d  (%d, %d)\n", hotspot.Count, hotspot.X, hotspot.Y)
+		}
+	}
+
+	first := len(report.Contradictions) - textContradictions
+	if first < 0 {
+		first = 0
+	}
+	if first > 0 {
+		fmt.Fprintf(&b, "\nlatest %d contradictions, the JSON report has them all:\n", textContradictions)
+	}
+	for _, contradiction := range report.Contradictions[first:] {
+		report.writeContradiction(&b, contradiction)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (report *Report) writeContradiction(b *strings.Builder, contradiction wfc.Contradiction) {
+	if len(contradiction.Domain) == 0 {
+		// Backtracking had already ruled out every tile here
+		fmt.Fprintf(b, "\nstep %d, (%d, %d) had no tiles left to try\n", contradiction.Step, contradiction.X, contradiction.Y)
+	} else {
+		fmt.Fprintf(b, "\nstep %d, (%d, %d) had %s\n", contradiction.Step, contradiction.X, contradiction.Y, report.labels(contradiction.Domain))
+	}
+	for dir, domain := range contradiction.Neighbours {
+		if domain != nil {
+			fmt.Fprintf(b, "  %s neighbour: %s\n", tileset.DirectionNames[dir], report.labels(domain))
+		}
+	}
+	for _, conflict := range contradiction.Conflicts {
+		fmt.Fprintf(b, "  %s %s %q needs %s %q, neighbour has %s\n", report.label(conflict.TileId), tileset.DirectionNames[conflict.Direction],
+			conflict.Connector, tileset.DirectionNames[(conflict.Direction+2)%4], conflict.Needed, quoteAll(conflict.Offered))
+	}
+	if len(contradiction.Chain) > 0 {
+		choices := make([]string, len(contradiction.Chain))
+		for idx, choice := range contradiction.Chain {
+			choices[idx] = fmt.Sprintf("(%d, %d) %s", choice.X, choice.Y, report.label(choice.TileId))
+		}
+		fmt.Fprintf(b, "  after %s\n", strings.Join(choices, ", "))
+	}
+}
+
+// Returns the label of a tile, or its ID if it isn't in the tileset
+func (report *Report) label(id int) string {
+	if id < 0 || id >= len(report.Tiles) {
+		return fmt.Sprintf("tile %d", id)
+	}
+	return report.Tiles[id]
+}
+
+func (report *Report) labels(ids []int) string {
+	if len(ids) == 0 {
+		return "no tiles"
+	}
+	labels := make([]string, len(ids))
+	for idx, id := range ids {
+		labels[idx] = report.label(id)
+	}
+	return strings.Join(labels, ", ")
+}
+
+func quoteAll(connectors []string) string {
+	if len(connectors) == 0 {
+		return "nothing"
+	}
+	quoted := make([]string, len(connectors))
+	for idx, connector := range connectors {
+		quoted[idx] = fmt.Sprintf("%q", connector)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func containsId(ids []int, id int) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"wavefunctioncollapse/ldtk"
+	"wavefunctioncollapse/mapfile"
+	"wavefunctioncollapse/render"
+	"wavefunctioncollapse/tiled"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+var generateCommand = command{
+	name:    "generate",
+	args:    "<tileset dir>",
+	summary: "generate a map and write it to a PNG or map file",
+	help: "Generates a single map from the tileset and writes it to -out, without opening a window.\n" +
+		"-out ending in .json, .csv or .bin writes the tile IDs instead of an image, see the README for the formats.\n" +
+		"-out ending in .tmx writes a Tiled map, with its tileset written next to it as a .tsx.\n" +
+		"-out ending in .ldtk writes an LDtk project, with its tileset image written next to it as <name>_tileset.png.\n" +
+		"-in fills in the undecided positions of a saved map, and any area given by -clear, keeping the rest.\n" +
+		"-checkpoint saves the solver's state every -checkpoint-every and on interrupt, -resume carries on from it.\n" +
+		"The grid and tile size default to the tileset's settings. Exits with status 1 if generation fails.",
+	run: runGenerate,
+}
+
+var viewCommand = command{
+	name:    "view",
+	args:    "<tileset dir>",
+	summary: "watch generation in a window (needs the gui build tag)",
+	help: "Opens a window animating generation of the tileset, see the README for the controls.\n" +
+		"Only available when built with -tags gui, as the window needs a display.",
+	run: runView,
+}
+
+var benchCommand = command{
+	name:    "bench",
+	args:    "<tileset dir>",
+	summary: "time repeated generations of a tileset",
+	help: "Generates -runs maps from the tileset and prints how long they took, without writing them.\n" +
+		"Exits with status 1 if any run fails.",
+	run: runBench,
+}
+
+// Limits on a single generation, shared by generate and bench
+type generationFlags struct {
+	timeout       time.Duration
+	maxSteps      int
+	maxBacktracks int
+	attempts      int
+}
+
+func (gen *generationFlags) options() wfc.Options {
+	return wfc.Options{MaxSteps: gen.maxSteps, MaxBacktracks: gen.maxBacktracks, Timeout: gen.timeout, Attempts: gen.attempts}
+}
+
+func runGenerate(flags *flag.FlagSet, args []string) int {
+	width := flags.Int("width", 0, "width of grid to collapse, defaults to the tileset's width or 32")
+	height := flags.Int("height", 0, "height of grid to collapse, defaults to the tileset's height or 18")
+	out := flags.String("out", "map.png", "file to write the map to, a .png image, a .json, .csv or .bin map, a .tmx Tiled map or an .ldtk LDtk project")
+	in := flags.String("in", "", "map file to keep the decided positions of, the grid size comes from the map")
+	clear := flags.String("clear", "", "area of the -in map to generate again, as x,y,width,height")
+	seed := flags.Int64("seed", 0, "seed for the generation, the same seed and tileset give the same map, 0 for a random seed")
+	tileSize := flags.Int("tilesize", 0, "pixel size of each tile, defaults to the tileset's tile size or the tile image size")
+	checkpoint := flags.String("checkpoint", "", "file to save the solver's state to while generating, gzipped if it ends in .gz")
+	checkpointEvery := flags.Duration("checkpoint-every", time.Minute, "how often to save -checkpoint")
+	resume := flags.String("resume", "", "checkpoint to carry on generating from, its grid size and seed are used")
+	cpuProfile := flags.String("cpuprofile", "", "write cpu profile to file")
+	gen := generationFlags{}
+	flags.DurationVar(&gen.timeout, "timeout", 0, "give up generating after this long, e.g. 30s, 0 for no limit")
+	flags.IntVar(&gen.maxSteps, "max-steps", 0, "give up generating after this many steps, 0 for no limit")
+	flags.IntVar(&gen.maxBacktracks, "max-backtracks", 0, "give up generating after this many backtracks, 0 for no limit")
+	flags.IntVar(&gen.attempts, "attempts", 1, "attempts to run at once with different seeds, the first to succeed is kept")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	clearArea, err := parseArea(*clear)
+	if err != nil || (*clear != "" && *in == "") {
+		fmt.Fprintf(flags.Output(), "invalid -clear %q, expected x,y,width,height with -in\n", *clear)
+		return exitUsage
+	}
+
+	if *resume != "" && (*in != "" || *seed != 0) {
+		fmt.Fprintf(flags.Output(), "-resume can't be used with -in or -seed, the checkpoint has its own\n")
+		return exitUsage
+	}
+	if *checkpoint != "" && gen.attempts > 1 {
+		fmt.Fprintf(flags.Output(), "-checkpoint can't be used with -attempts, each attempt would overwrite it\n")
+		return exitUsage
+	}
+
+	if !isOutputFormat(*out) {
+		fmt.Fprintf(flags.Output(), "unknown format for -out %s, expected .png, .json, .csv, .bin, .tmx or .ldtk\n", *out)
+		return exitUsage
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	writer := newResultWriter(ts, *tileSize)
+	if err := writer.prepare(*out); err != nil {
+		return fail(err)
+	}
+
+	stopProfile, err := startProfile(*cpuProfile)
+	if err != nil {
+		return fail(err)
+	}
+	defer stopProfile()
+
+	opts := gen.options()
+	opts.Seed = *seed
+	gridWidth, gridHeight := gridSize(ts.Settings, *width, *height)
+	if *in != "" {
+		existing, err := mapfile.Read(*in)
+		if err != nil {
+			return fail(err)
+		}
+		if err := existing.Check(ts); err != nil {
+			return fail(fmt.Errorf("can't use %s with %s: %v", *in, ts.Dir(), err))
+		}
+
+		gridWidth, gridHeight = existing.Width, existing.Height
+		opts.Constraints = existing.Constraints(clearArea)
+	}
+	if *resume == "" {
+		// A checkpoint has its own grid, checked when it's resumed
+		if err := checkGrid(ts, gridWidth, gridHeight, opts.Constraints); err != nil {
+			return fail(err)
+		}
+	}
+
+	ctx := context.Background()
+	if *checkpoint != "" {
+		// Stopping with Ctrl-C saves a last checkpoint rather than losing the work
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		opts.Checkpoint = func(snapshot *wfc.Snapshot) error {
+			return writeSnapshot(*checkpoint, snapshot)
+		}
+		opts.CheckpointEvery = *checkpointEvery
+	}
+
+	solver, err := resumeSolver(ts, *resume, opts)
+	if err != nil {
+		return fail(err)
+	}
+	var res [][]int
+	if solver != nil {
+		res, err = solver.Run(ctx)
+	} else {
+		solver, res, err = wfc.Solve(ctx, ts.WfcTiles(), gridWidth, gridHeight, opts)
+	}
+	if err != nil {
+		if *checkpoint != "" {
+			log.Printf("resume from the last checkpoint with -resume=%s", *checkpoint)
+		}
+		return fail(fmt.Errorf("%v, seed %d", err, solver.Seed()))
+	}
+
+	if err := writer.write(*out, res, solver.Seed()); err != nil {
+		return fail(err)
+	}
+
+	return exitOK
+}
+
+// Returns a solver carrying on from the checkpoint at resumePath, nil if there's no checkpoint to resume
+func resumeSolver(ts *tileset.Tileset, resumePath string, opts wfc.Options) (*wfc.Solver, error) {
+	if resumePath == "" {
+		return nil, nil
+	}
+
+	snapshot, err := readSnapshot(resumePath)
+	if err != nil {
+		return nil, err
+	}
+	if !reflect.DeepEqual(snapshot.Tiles, ts.WfcTiles()) {
+		return nil, fmt.Errorf("can't resume %s with %s, the checkpoint is from another tileset or an older version of it", resumePath, ts.Dir())
+	}
+
+	solver, err := wfc.Resume(snapshot, opts)
+	if err != nil {
+		return nil, fmt.Errorf("can't resume %s: %v", resumePath, err)
+	}
+	return solver, nil
+}
+
+// Returns an error if the tileset can't generate a grid of the size with the constraints, e.g. as it has a single tile
+func checkGrid(ts *tileset.Tileset, width, height int, constraints []wfc.Constraint) error {
+	if err := wfc.CheckGrid(ts.WfcTiles(), width, height, constraints); err != nil {
+		return fmt.Errorf("can't generate from %s: %v", ts.Dir(), err)
+	}
+	return nil
+}
+
+// Reports whether generate can write to a file, from its extension
+func isOutputFormat(outPath string) bool {
+	switch strings.ToLower(path.Ext(outPath)) {
+	case ".png", ".tmx", ".ldtk":
+		return true
+	}
+	return mapfile.IsMapFile(outPath)
+}
+
+// Writes generated results in the format given by each file's extension
+// Tile images are loaded by prepare or the first time a PNG is written, and are shared between goroutines
+type resultWriter struct {
+	ts        *tileset.Tileset
+	tileSize  int // pixel size of each tile in a PNG, 0 for the tileset's tile size or the tile image size
+	loadOnce  sync.Once
+	images    map[int]image.Image
+	imagesErr error
+}
+
+func newResultWriter(ts *tileset.Tileset, tileSize int) *resultWriter {
+	if tileSize <= 0 {
+		tileSize = ts.Settings.TileSize
+	}
+	return &resultWriter{ts: ts, tileSize: tileSize}
+}
+
+// Writes a result generated with seed, rendering it for a PNG
+func (w *resultWriter) write(outPath string, res [][]int, seed int64) error {
+	if mapfile.IsMapFile(outPath) {
+		return mapfile.Write(outPath, mapfile.New(w.ts, res, seed))
+	}
+
+	switch strings.ToLower(path.Ext(outPath)) {
+	case ".tmx":
+		return tiled.WriteMap(w.ts, res, seed, outPath)
+	case ".ldtk":
+		return ldtk.WriteProject(w.ts, res, outPath)
+	}
+
+	if err := w.loadImages(); err != nil {
+		return err
+	}
+	return render.SavePNG(outPath, res, w.images, w.tileSize)
+}
+
+// Loads the tile images the first time it's called, returning the same error every time after
+func (w *resultWriter) loadImages() error {
+	w.loadOnce.Do(func() {
+		w.images, w.imagesErr = render.LoadImages(w.ts)
+	})
+	return w.imagesErr
+}
+
+// Loads the tile images now if writing outPath needs them, so a missing or corrupt image fails before generating rather than after
+func (w *resultWriter) prepare(outPath string) error {
+	switch strings.ToLower(path.Ext(outPath)) {
+	case ".png", ".ldtk":
+		return w.loadImages()
+	}
+	return nil
+}
+
+// Parses an area given as x,y,width,height, an empty area for an empty string
+func parseArea(area string) (image.Rectangle, error) {
+	if area == "" {
+		return image.Rectangle{}, nil
+	}
+
+	var x, y, w, h int
+	if _, err := fmt.Sscanf(area, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil || w <= 0 || h <= 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid area %q", area)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
+
+func runView(flags *flag.FlagSet, args []string) int {
+	width := flags.Int("width", 0, "width of grid to collapse, defaults to the tileset's width or 32")
+	height := flags.Int("height", 0, "height of grid to collapse, defaults to the tileset's height or 18")
+	mapFile := flags.String("map", "", "map file to open, its decided positions are kept and the rest generated")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	gridWidth, gridHeight := gridSize(ts.Settings, *width, *height)
+	var constraints []wfc.Constraint
+	if *mapFile != "" {
+		existing, err := mapfile.Read(*mapFile)
+		if err != nil {
+			return fail(err)
+		}
+		if err := existing.Check(ts); err != nil {
+			return fail(fmt.Errorf("can't use %s with %s: %v", *mapFile, ts.Dir(), err))
+		}
+
+		gridWidth, gridHeight = existing.Width, existing.Height
+		constraints = existing.Constraints(image.Rectangle{})
+	}
+	if err := checkGrid(ts, gridWidth, gridHeight, constraints); err != nil {
+		return fail(err)
+	}
+
+	if err := openViewer(ts, gridWidth, gridHeight, constraints); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runBench(flags *flag.FlagSet, args []string) int {
+	width := flags.Int("width", 0, "width of grid to collapse, defaults to the tileset's width or 32")
+	height := flags.Int("height", 0, "height of grid to collapse, defaults to the tileset's height or 18")
+	runs := flags.Int("runs", 10, "number of maps to generate")
+	cpuProfile := flags.String("cpuprofile", "", "write cpu profile to file")
+	gen := generationFlags{}
+	flags.DurationVar(&gen.timeout, "timeout", time.Minute, "give up a run after this long, 0 for no limit")
+	flags.IntVar(&gen.maxSteps, "max-steps", 0, "give up a run after this many steps, 0 for no limit")
+	flags.IntVar(&gen.maxBacktracks, "max-backtracks", 0, "give up a run after this many backtracks, 0 for no limit")
+	flags.IntVar(&gen.attempts, "attempts", 1, "attempts to run at once for each run with different seeds, the first to succeed is kept")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	if *runs <= 0 {
+		fmt.Fprintf(flags.Output(), "-runs must be positive, got %d\n", *runs)
+		return exitUsage
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	stopProfile, err := startProfile(*cpuProfile)
+	if err != nil {
+		return fail(err)
+	}
+	defer stopProfile()
+
+	tiles := ts.WfcTiles()
+	gridWidth, gridHeight := gridSize(ts.Settings, *width, *height)
+	if err := checkGrid(ts, gridWidth, gridHeight, nil); err != nil {
+		return fail(err)
+	}
+	var total, fastest, slowest time.Duration
+	failures := 0
+	for run := 0; run < *runs; run++ {
+		start := time.Now()
+		_, err := wfc.CollapseContext(context.Background(), tiles, gridWidth, gridHeight, gen.options())
+		elapsed := time.Since(start)
+
+		total += elapsed
+		if run == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+		if elapsed > slowest {
+			slowest = elapsed
+		}
+
+		if err != nil {
+			failures++
+			var stopErr *wfc.StopError
+			if errors.As(err, &stopErr) {
+				fmt.Printf("run %d failed after %v: %v\n", run+1, elapsed, stopErr.Reason)
+			} else {
+				fmt.Printf("run %d failed after %v: %v\n", run+1, elapsed, err)
+			}
+		}
+	}
+
+	fmt.Printf("%d runs of %dx%d in %v: mean %v, fastest %v, slowest %v, %d failed\n",
+		*runs, gridWidth, gridHeight, total, total/time.Duration(*runs), fastest, slowest, failures)
+	if failures > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -3,8 +3,10 @@ module wavefunctioncollapse
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.3.2 // direct
 	github.com/disintegration/imaging v1.6.2 // direct
 	github.com/hajimehoshi/ebiten v1.12.12 // direct
+	gopkg.in/yaml.v3 v3.0.1 // direct
 )
 
 require (
@@ -1,123 +1,322 @@
 package gui
 
 import (
-	"encoding/json"
 	"fmt"
-	"image"
-	"os"
-	"path"
+	"image/color"
+	"math"
+	"wavefunctioncollapse/tileset"
 	"wavefunctioncollapse/wfc"
 
 	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
 )
 
-type ConfigTile struct {
-	Name        string         `json:"name"`
-	Connections map[int]string `json:"connections"`
-}
-
 type tileImage struct {
-	img *ebiten.Image // image to output
+	img       *ebiten.Image // image to output, shared by every variant of a tile
+	transform ebiten.GeoM   // rotates and mirrors the image into this variant
 }
 
+const (
+	minSpeed          = 1.0 / 64 // slowest playback, in steps per tick
+	maxSpeed          = 4096     // fastest playback, in steps per tick
+	highlightTicks    = 30       // how long a backtracked position stays highlighted
+	maxStepsPerFinish = 64       // steps allowed per position when skipping to the end
+	minCellSize       = 1        // smallest size in pixels a position is drawn at
+)
+
+var (
+	backtrackColour     = color.NRGBA{0xff, 0x30, 0x30, 0xa0}
+	contradictionColour = color.RGBA{0x80, 0x00, 0x00, 0xff}
+)
+
 type Simulation struct {
 	tileImages                 map[int]*tileImage
+	tileNames                  map[int]string // display name from the config for each tile ID
 	tileSet                    []wfc.Tile
-	result                     *[][]int
+	constraints                []wfc.Constraint // kept by every generation alongside the painted ones, single tiles are painted as pins instead
+	solver                     *wfc.Solver
+	playing                    bool                   // steps automatically each tick when true
+	speed                      float64                // steps per tick while playing
+	stepBudget                 float64                // partial steps carried over between ticks
+	highlights                 map[[2]int]int         // backtracked positions and the ticks left to highlight them
+	heatmap                    heatmapMode            // colours positions by how undecided they are
+	inspector                  bool                   // shows the candidates of the position under the cursor
+	painting                   bool                   // paint mode, where clicks paint constraints instead of restarting
+	painted                    map[[2]int]brush       // tiles pinned and tags brushed at positions, kept by every generation
+	palette                    []brush                // choices in the paint mode sidebar, erase first, then tags, then tiles
+	selected                   int                    // index of the palette's brush used by left clicks
+	paletteScroll              int                    // palette entries scrolled past
+	tagIds                     map[string][]int       // IDs of the tiles with each tag
+	tagColours                 map[string]color.NRGBA // tint of each tag's positions while painting
+	conflicts                  map[[2]int]bool        // painted positions no tile can satisfy next to a painted neighbour
 	width, height              int
 	aspectRatioX, aspectRatioY int
 	screenWidth, screenHeight  int
 }
 
-func RunSimulation(tileDir string, width, height int) {
+// Opens a window generating from the tileset, every generation keeps to the constraints
+func RunSimulation(ts *tileset.Tileset, width, height int, constraints []wfc.Constraint) {
 	ebiten.SetWindowSize(1600, 900)
 	ebiten.SetWindowTitle("Wave function collapse")
-
-	configPath := path.Join(tileDir, "config.json")
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		panic(fmt.Errorf("failed to read file %s with err %v", configPath, err))
-	}
-
-	var config []ConfigTile
-	err = json.Unmarshal(data, &config)
-	if err != nil {
-		panic(fmt.Errorf("failed to marshal data with err %v", err))
+	if ts.Settings.Name != "" {
+		ebiten.SetWindowTitle("Wave function collapse - " + ts.Settings.Name)
 	}
 
-	tiles := make(map[int]*tileImage, len(config))
-	tileSet := make([]wfc.Tile, 0, len(config))
-	for tileIdx, tile := range config {
-		id := tileIdx
-		conn := tile.Connections
-		tileSet = append(tileSet, wfc.Tile{Id: id, Configuration: conn})
+	loader := ts.Loader()
+	tiles := make(map[int]*tileImage, len(ts.Tiles))
+	names := make(map[int]string, len(ts.Tiles))
+	// Variants of a tile share its base image, so only load each image once
+	baseImages := make(map[string]*ebiten.Image, len(ts.Tiles))
+	for id, tile := range ts.Tiles {
+		ebitenImg, ok := baseImages[tile.Name]
+		if !ok {
+			img, err := loader.BaseImage(tile.Name)
+			if err != nil {
+				panic(err)
+			}
 
-		imgPath := path.Join(tileDir, tile.Name)
-		imgReader, err := os.Open(imgPath)
-		if err != nil {
-			panic(fmt.Errorf("failed to open image %s with error %v", imgPath, err))
-		}
-		defer imgReader.Close()
-		img, _, err := image.Decode(imgReader)
-		if err != nil {
-			panic(fmt.Errorf("failed to decode image %s with error %v", imgPath, err))
-		}
-
-		ebitenImg, err := ebiten.NewImageFromImage(img, ebiten.FilterDefault)
-		if err != nil {
-			panic(fmt.Errorf("failed to convert to ebiten image, path %s with error %v", imgPath, err))
+			ebitenImg, err = ebiten.NewImageFromImage(img, ebiten.FilterDefault)
+			if err != nil {
+				panic(fmt.Errorf("failed to convert to ebiten image, tile %s with error %v", tile.Name, err))
+			}
+			baseImages[tile.Name] = ebitenImg
 		}
 
+		imgWidth, imgHeight := ebitenImg.Size()
 		tiles[id] = &tileImage{
 			ebitenImg,
+			transformGeoM(tile.Transform, imgWidth, imgHeight),
 		}
+		names[id] = tile.Label()
 	}
 
-	res := wfc.Collapse(tileSet, width, height)
-	sim := Simulation{
+	sim := &Simulation{
 		tileImages:   tiles,
-		tileSet:      tileSet,
+		tileNames:    names,
+		tileSet:      ts.WfcTiles(),
 		width:        width,
 		height:       height,
-		result:       &res,
+		playing:      true,
+		speed:        1,
+		highlights:   make(map[[2]int]int),
 		aspectRatioX: 16, aspectRatioY: 9,
 		screenWidth: 1280, screenHeight: 720,
 	}
+	sim.setupPaint(ts, constraints)
+	sim.restart()
 
 	if err := ebiten.RunGame(sim); err != nil {
 		panic(err)
 	}
 }
 
-func (g Simulation) Update(screen *ebiten.Image) error {
-	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
-		res := wfc.Collapse(g.tileSet, g.width, g.height)
-		*g.result = res
+// Returns the GeoM applying a tile's transform to an image of the given size, keeping it within the same bounds
+func transformGeoM(transform tileset.Transform, width, height int) ebiten.GeoM {
+	geom := ebiten.GeoM{}
+	geom.Translate(-float64(width)/2, -float64(height)/2)
+	for _, op := range transform {
+		switch op {
+		case 'R':
+			geom.Rotate(math.Pi / 2)
+		case 'H':
+			geom.Scale(-1, 1)
+		case 'V':
+			geom.Scale(1, -1)
+		case 'F':
+			geom.Rotate(math.Pi)
+		}
+	}
+	geom.Translate(float64(width)/2, float64(height)/2)
+
+	return geom
+}
+
+// Starts a new generation around the painted constraints, discarding the current one
+func (sim *Simulation) restart() {
+	sim.solver = wfc.NewSolver(sim.tileSet, sim.width, sim.height, wfc.Options{Constraints: sim.generationConstraints()})
+	sim.solver.AddObserver(sim)
+	sim.stepBudget = 0
+	for pos := range sim.highlights {
+		delete(sim.highlights, pos)
+	}
+}
+
+// Highlights positions involved in backtracking so they stand out while animating
+func (sim *Simulation) OnEvent(event wfc.Event) {
+	if event.Type == wfc.EventBacktrack || event.Type == wfc.EventContradiction {
+		sim.highlights[[2]int{event.X, event.Y}] = highlightTicks
+	}
+}
+
+func (sim *Simulation) Update(screen *ebiten.Image) error {
+	if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+		sim.painting = !sim.painting
+		sim.playing = false
+	}
+
+	if sim.painting {
+		sim.updatePaint()
+		return nil
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || inpututil.IsKeyJustPressed(ebiten.KeyR) {
+		sim.restart()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		sim.playing = !sim.playing
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+		sim.heatmap = (sim.heatmap + 1) % heatmapModes
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyI) {
+		sim.inspector = !sim.inspector
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyUp) && sim.speed < maxSpeed {
+		sim.speed *= 2
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyDown) && sim.speed > minSpeed {
+		sim.speed /= 2
+	}
+
+	steps := 0
+	if sim.playing {
+		// Speed can be below one step per tick, so carry over partial steps
+		sim.stepBudget += sim.speed
+		steps = int(sim.stepBudget)
+		sim.stepBudget -= float64(steps)
+	} else if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+		steps = 1
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+		// Skip to the end of the generation
+		steps = sim.width * sim.height * maxStepsPerFinish
+	}
+
+	for step := 0; step < steps && !sim.solver.Done(); step++ {
+		if _, err := sim.solver.Step(); err != nil {
+			sim.playing = false
+		}
+	}
+
+	for pos, ticks := range sim.highlights {
+		if ticks <= 1 {
+			delete(sim.highlights, pos)
+		} else {
+			sim.highlights[pos] = ticks - 1
+		}
 	}
 
 	return nil
 }
 
-func (sim Simulation) Draw(screen *ebiten.Image) {
-	imgIds := *sim.result
-	for row := range imgIds {
-		for col := range imgIds[row] {
-			img := sim.tileImages[imgIds[row][col]]
-			imgWidth, imgHeight := img.img.Size()
-
-			imgOptions := ebiten.DrawImageOptions{}
-			tileLen := float64(sim.screenWidth / sim.width)
-			tileWid := float64(sim.screenHeight / sim.height)
-			imgOptions.GeoM.Scale(
-				tileLen/float64(imgWidth),
-				tileWid/float64(imgHeight))
-			// due to order of rows returned, need to place them at the bottom
-			imgOptions.GeoM.Translate(tileLen*float64(row), tileWid*float64(col))
-			screen.DrawImage(img.img, &imgOptions)
+func (sim *Simulation) Draw(screen *ebiten.Image) {
+	if sim.painting {
+		sim.drawPaint(screen)
+		sim.drawStatus(screen)
+		return
+	}
+
+	tileLen, tileWid := sim.cellSize()
+	for row := 0; row < sim.width; row++ {
+		for col := 0; col < sim.height; col++ {
+			x, y := tileLen*float64(row), tileWid*float64(col)
+			domain := sim.solver.Domain(row, col)
+			if len(domain) == 0 {
+				// No tiles left, the solver will backtrack from here
+				ebitenutil.DrawRect(screen, x, y, tileLen, tileWid, contradictionColour)
+				continue
+			}
+
+			// Collapsed positions have one tile, otherwise blend the candidates into their average
+			// Drawing the nth candidate at 1/n opacity keeps an even mix of everything drawn so far
+			for idx, id := range domain {
+				sim.drawTile(screen, id, x, y, tileLen, tileWid, 1/float64(idx+1))
+			}
 		}
 	}
+
+	for pos, ticks := range sim.highlights {
+		highlight := backtrackColour
+		highlight.A = uint8(int(highlight.A) * ticks / highlightTicks)
+		ebitenutil.DrawRect(screen, tileLen*float64(pos[0]), tileWid*float64(pos[1]), tileLen, tileWid, highlight)
+	}
+
+	sim.drawHeatmap(screen)
+	sim.drawStatus(screen)
+	sim.drawInspector(screen)
+}
+
+// Draws a tile scaled into the given rectangle, at the given opacity
+func (sim *Simulation) drawTile(screen *ebiten.Image, id int, x, y, width, height, alpha float64) {
+	img := sim.tileImages[id]
+	imgWidth, imgHeight := img.img.Size()
+
+	imgOptions := ebiten.DrawImageOptions{}
+	imgOptions.GeoM = img.transform
+	imgOptions.GeoM.Scale(
+		width/float64(imgWidth),
+		height/float64(imgHeight))
+	imgOptions.GeoM.Translate(x, y)
+	imgOptions.ColorM.Scale(1, 1, 1, alpha)
+	screen.DrawImage(img.img, &imgOptions)
+}
+
+// Returns the pixel size of a position, the grid narrows to make room for the palette while painting
+func (sim *Simulation) cellSize() (width, height float64) {
+	gridWidth := sim.screenWidth
+	if sim.painting {
+		gridWidth -= paletteWidth
+	}
+	// Grids with more positions than pixels overflow the screen rather than shrinking positions to nothing
+	width = math.Max(float64(gridWidth)/float64(sim.width), minCellSize)
+	height = math.Max(float64(sim.screenHeight)/float64(sim.height), minCellSize)
+	return width, height
+}
+
+// Returns the position under a pixel, false if it's outside the grid
+func (sim *Simulation) cellAt(x, y int) (row, col int, ok bool) {
+	tileLen, tileWid := sim.cellSize()
+	row, col = int(float64(x)/tileLen), int(float64(y)/tileWid)
+	if x < 0 || y < 0 || row >= sim.width || col >= sim.height {
+		return 0, 0, false
+	}
+	return row, col, true
+}
+
+// Prints the playback state and controls in the corner of the screen
+func (sim *Simulation) drawStatus(screen *ebiten.Image) {
+	if sim.painting {
+		ebitenutil.DebugPrint(screen, fmt.Sprintf(
+			"painting | brush %s | %d painted | %d conflicts\n"+
+				"left click: paint  right click: erase  wheel: scroll palette  c: clear  g: generate  p: back to generation",
+			sim.brushName(sim.palette[sim.selected]), len(sim.painted), len(sim.conflicts)))
+		return
+	}
+
+	state := "paused"
+	if sim.playing {
+		state = "playing"
+	}
+	if sim.solver.Done() {
+		state = "finished"
+		if err := sim.solver.Err(); err != nil {
+			state = err.Error()
+		}
+	}
+
+	ebitenutil.DebugPrint(screen, fmt.Sprintf(
+		"%s | speed %g steps/tick | steps %d | backtracks %d | heatmap %s\n"+
+			"space: play/pause  right: step  up/down: speed  enter: finish  r/click: restart  h: heatmap  i: inspector  p: paint",
+		state, sim.speed, sim.solver.Steps(), sim.solver.Backtracks(), sim.heatmap))
 }
 
-func (sim Simulation) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
+func (sim *Simulation) Layout(outsideWidth, outsideHeight int) (screenWidth, screenHeight int) {
 	return sim.screenWidth, sim.screenHeight
 }
@@ -0,0 +1,156 @@
+package gui
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// heatmapMode selects what the heatmap overlay colours positions by
+type heatmapMode int
+
+const (
+	heatmapOff     heatmapMode = iota
+	heatmapOptions             // number of tiles still possible
+	heatmapEntropy             // shannon entropy of the tiles still possible
+	heatmapModes               // number of modes, used to cycle through them
+)
+
+const (
+	heatmapAlpha      = 0xb0 // opacity of the heatmap over the tiles
+	inspectorMaxTiles = 16   // candidates listed before the inspector truncates
+	debugCharWidth    = 6    // width in pixels of a debug print character
+	debugLineHeight   = 16   // height in pixels of a debug print line
+)
+
+var inspectorBackground = color.NRGBA{0x00, 0x00, 0x00, 0xc0}
+
+func (mode heatmapMode) String() string {
+	switch mode {
+	case heatmapOptions:
+		return "options"
+	case heatmapEntropy:
+		return "entropy"
+	default:
+		return "off"
+	}
+}
+
+// Colours each undecided position from blue (nearly decided) to red (nothing decided yet)
+// Collapsed positions are left uncoloured, positions with no options are coloured black
+func (sim *Simulation) drawHeatmap(screen *ebiten.Image) {
+	if sim.heatmap == heatmapOff {
+		return
+	}
+
+	allIds := make([]int, len(sim.tileSet))
+	for idx, tile := range sim.tileSet {
+		allIds[idx] = tile.Id
+	}
+	maxValue := sim.heatmapValue(allIds)
+	tileLen, tileWid := sim.cellSize()
+	for row := 0; row < sim.width; row++ {
+		for col := 0; col < sim.height; col++ {
+			if sim.solver.Collapsed(row, col) {
+				continue
+			}
+
+			domain := sim.solver.Domain(row, col)
+			clr := color.NRGBA{0x00, 0x00, 0x00, heatmapAlpha}
+			if len(domain) > 0 && maxValue > 0 {
+				clr = heatColour(sim.heatmapValue(domain) / maxValue)
+			}
+			ebitenutil.DrawRect(screen, tileLen*float64(row), tileWid*float64(col), tileLen, tileWid, clr)
+		}
+	}
+}
+
+// Returns the heatmap value of a position with the given tiles still possible
+func (sim *Simulation) heatmapValue(domain []int) float64 {
+	if sim.heatmap == heatmapEntropy {
+		// Tiles are picked in proportion to their weight, unset weights count as 1
+		total, weighted := 0.0, 0.0
+		for _, id := range domain {
+			weight := sim.tileSet[id].Weight
+			if weight <= 0 {
+				weight = 1
+			}
+			total += weight
+			weighted += weight * math.Log2(weight)
+		}
+		return math.Log2(total) - weighted/total
+	}
+
+	return float64(len(domain) - 1)
+}
+
+// Returns a colour between blue for 0 and red for 1
+func heatColour(value float64) color.NRGBA {
+	value = math.Max(0, math.Min(1, value))
+	return color.NRGBA{
+		uint8(255 * value),
+		uint8(255 * (1 - math.Abs(value-0.5)*2)),
+		uint8(255 * (1 - value)),
+		heatmapAlpha,
+	}
+}
+
+// Shows the candidate tiles of the position under the cursor, or the tile and its connectors if collapsed
+func (sim *Simulation) drawInspector(screen *ebiten.Image) {
+	if !sim.inspector {
+		return
+	}
+
+	cursorX, cursorY := ebiten.CursorPosition()
+	row, col, ok := sim.cellAt(cursorX, cursorY)
+	if !ok {
+		return
+	}
+
+	lines := []string{fmt.Sprintf("position (%d, %d)", row, col)}
+	domain := sim.solver.Domain(row, col)
+	if sim.solver.Collapsed(row, col) {
+		tile := sim.tileSet[domain[0]]
+		lines = append(lines,
+			fmt.Sprintf("collapsed: %s (id %d)", sim.tileNames[tile.Id], tile.Id),
+			fmt.Sprintf("left:  %s", tile.Configuration[wfc.LEFT]),
+			fmt.Sprintf("up:    %s", tile.Configuration[wfc.UP]),
+			fmt.Sprintf("right: %s", tile.Configuration[wfc.RIGHT]),
+			fmt.Sprintf("down:  %s", tile.Configuration[wfc.DOWN]),
+		)
+	} else {
+		lines = append(lines, fmt.Sprintf("%d candidates", len(domain)))
+		for idx, id := range domain {
+			if idx == inspectorMaxTiles {
+				lines = append(lines, fmt.Sprintf("... %d more", len(domain)-idx))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("- %s (id %d)", sim.tileNames[id], id))
+		}
+	}
+
+	width := 0
+	for _, line := range lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	boxWidth, boxHeight := width*debugCharWidth+8, len(lines)*debugLineHeight+8
+
+	// Keep the box on screen by flipping it to the other side of the cursor near the edges
+	x, y := cursorX+12, cursorY+12
+	if x+boxWidth > sim.screenWidth {
+		x = cursorX - boxWidth - 4
+	}
+	if y+boxHeight > sim.screenHeight {
+		y = cursorY - boxHeight - 4
+	}
+
+	ebitenutil.DrawRect(screen, float64(x), float64(y), float64(boxWidth), float64(boxHeight), inspectorBackground)
+	ebitenutil.DebugPrintAt(screen, strings.Join(lines, "\n"), x+4, y+4)
+}
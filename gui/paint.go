@@ -0,0 +1,274 @@
+package gui
+
+import (
+	"fmt"
+	"image/color"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/hajimehoshi/ebiten"
+	"github.com/hajimehoshi/ebiten/ebitenutil"
+	"github.com/hajimehoshi/ebiten/inpututil"
+)
+
+// brushKind is what painting a position with a brush does
+type brushKind int
+
+const (
+	brushErase brushKind = iota // removes whatever was painted
+	brushTile                   // pins a single tile
+	brushTag                    // restricts the position to the tiles with a tag
+)
+
+// brush is an entry of the palette, and what's painted at a position
+type brush struct {
+	kind   brushKind
+	tileId int    // tile pinned by a tile brush
+	tag    string // tag of a tag brush
+}
+
+const (
+	paletteWidth       = 240 // width in pixels of the palette sidebar
+	paletteEntryHeight = 24  // height in pixels of a palette entry
+	paletteIconSize    = 20  // size in pixels of a tile or tag swatch in the palette
+	paletteScrollSpeed = 3   // entries scrolled per notch of the mouse wheel
+	outlineWidth       = 2   // thickness in pixels of cell outlines
+)
+
+var (
+	paletteBackground = color.NRGBA{0x20, 0x20, 0x20, 0xff}
+	selectedColour    = color.NRGBA{0x50, 0x50, 0x70, 0xff}
+	emptyCellColour   = color.NRGBA{0x30, 0x30, 0x30, 0xff}
+	cursorColour      = color.NRGBA{0xff, 0xff, 0xff, 0xc0}
+	conflictColour    = color.NRGBA{0xff, 0x30, 0x30, 0xff}
+	// Tags take colours in order, repeating if there are more tags than colours
+	tagPalette = []color.NRGBA{
+		{0x30, 0x80, 0xff, 0xc0},
+		{0x30, 0xc0, 0x50, 0xc0},
+		{0xff, 0xa0, 0x20, 0xc0},
+		{0xa0, 0x50, 0xe0, 0xc0},
+		{0xf0, 0xe0, 0x30, 0xc0},
+		{0x20, 0xc0, 0xc0, 0xc0},
+	}
+)
+
+// Builds the palette from the tileset and paints the single tile constraints as pins, so they can be erased
+func (sim *Simulation) setupPaint(ts *tileset.Tileset, constraints []wfc.Constraint) {
+	sim.painted = make(map[[2]int]brush)
+	sim.tagIds = make(map[string][]int)
+	sim.tagColours = make(map[string]color.NRGBA)
+	sim.palette = []brush{{kind: brushErase}}
+	for idx, tag := range ts.Tags() {
+		sim.tagIds[tag] = ts.TaggedIds(tag)
+		sim.tagColours[tag] = tagPalette[idx%len(tagPalette)]
+		sim.palette = append(sim.palette, brush{kind: brushTag, tag: tag})
+	}
+	for _, tile := range sim.tileSet {
+		sim.palette = append(sim.palette, brush{kind: brushTile, tileId: tile.Id})
+	}
+	// Start with the first tile, so clicking straight away pins something
+	sim.selected = len(sim.tagIds) + 1
+
+	for _, constraint := range constraints {
+		if len(constraint.Tiles) == 1 {
+			sim.painted[[2]int{constraint.X, constraint.Y}] = brush{kind: brushTile, tileId: constraint.Tiles[0]}
+		} else {
+			sim.constraints = append(sim.constraints, constraint)
+		}
+	}
+	sim.findConflicts()
+}
+
+// Returns the constraints for a generation, the painted positions followed by the ones that can't be painted
+func (sim *Simulation) generationConstraints() []wfc.Constraint {
+	constraints := make([]wfc.Constraint, 0, len(sim.painted)+len(sim.constraints))
+	for pos, painted := range sim.painted {
+		constraints = append(constraints, wfc.Constraint{X: pos[0], Y: pos[1], Tiles: sim.brushTiles(painted)})
+	}
+	return append(constraints, sim.constraints...)
+}
+
+// Returns the IDs of the tiles a position painted with the brush allows
+func (sim *Simulation) brushTiles(b brush) []int {
+	if b.kind == brushTag {
+		return sim.tagIds[b.tag]
+	}
+	return []int{b.tileId}
+}
+
+// Handles input in paint mode: painting and erasing positions, choosing from the palette and generating
+func (sim *Simulation) updatePaint() {
+	if _, dy := ebiten.Wheel(); dy != 0 {
+		sim.paletteScroll -= int(dy) * paletteScrollSpeed
+		visible := sim.screenHeight / paletteEntryHeight
+		if sim.paletteScroll > len(sim.palette)-visible {
+			sim.paletteScroll = len(sim.palette) - visible
+		}
+		if sim.paletteScroll < 0 {
+			sim.paletteScroll = 0
+		}
+	}
+
+	cursorX, cursorY := ebiten.CursorPosition()
+	if cursorX >= sim.screenWidth-paletteWidth {
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			entry := cursorY/paletteEntryHeight + sim.paletteScroll
+			if entry >= 0 && entry < len(sim.palette) {
+				sim.selected = entry
+			}
+		}
+	} else if row, col, ok := sim.cellAt(cursorX, cursorY); ok {
+		// Held buttons paint every position the cursor is dragged over
+		if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			sim.paint(row, col, sim.palette[sim.selected])
+		} else if ebiten.IsMouseButtonPressed(ebiten.MouseButtonRight) {
+			sim.paint(row, col, brush{kind: brushErase})
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+		sim.painted = make(map[[2]int]brush)
+		sim.findConflicts()
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		// Generate around the painting, watching it from the start
+		sim.painting = false
+		sim.playing = true
+		sim.restart()
+	}
+}
+
+// Paints a position with the brush, erasing it with the erase brush
+func (sim *Simulation) paint(row, col int, b brush) {
+	pos := [2]int{row, col}
+	if current, ok := sim.painted[pos]; (ok && current == b) || (!ok && b.kind == brushErase) {
+		return
+	}
+
+	if b.kind == brushErase {
+		delete(sim.painted, pos)
+	} else {
+		sim.painted[pos] = b
+	}
+	sim.findConflicts()
+}
+
+// Marks the painted positions that can't sit next to a painted neighbour, as no pair of their tiles match
+// Only direct neighbours are checked, so a painting without conflicts can still be impossible to generate
+func (sim *Simulation) findConflicts() {
+	sim.conflicts = make(map[[2]int]bool)
+	for pos, painted := range sim.painted {
+		for _, dir := range []int{wfc.RIGHT, wfc.DOWN} {
+			neighbourPos := [2]int{pos[0] + 1, pos[1]}
+			if dir == wfc.DOWN {
+				neighbourPos = [2]int{pos[0], pos[1] + 1}
+			}
+			neighbour, ok := sim.painted[neighbourPos]
+			if !ok || sim.anyMatch(dir, sim.brushTiles(painted), sim.brushTiles(neighbour)) {
+				continue
+			}
+			sim.conflicts[pos] = true
+			sim.conflicts[neighbourPos] = true
+		}
+	}
+}
+
+// Reports if any of the tiles can have any of the neighbours next to it in the given direction
+func (sim *Simulation) anyMatch(dir int, tiles, neighbours []int) bool {
+	for _, id := range tiles {
+		for _, neighbourId := range neighbours {
+			if wfc.Matches(dir, sim.tileSet[id], sim.tileSet[neighbourId]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Draws the painted positions over an empty grid, with the palette down the right hand side
+func (sim *Simulation) drawPaint(screen *ebiten.Image) {
+	tileLen, tileWid := sim.cellSize()
+	for row := 0; row < sim.width; row++ {
+		for col := 0; col < sim.height; col++ {
+			x, y := tileLen*float64(row), tileWid*float64(col)
+			painted, ok := sim.painted[[2]int{row, col}]
+			switch {
+			case !ok:
+				// Leave a gap between positions so the grid can be seen
+				ebitenutil.DrawRect(screen, x, y, tileLen-1, tileWid-1, emptyCellColour)
+			case painted.kind == brushTile:
+				sim.drawTile(screen, painted.tileId, x, y, tileLen, tileWid, 1)
+			case painted.kind == brushTag:
+				ebitenutil.DrawRect(screen, x, y, tileLen, tileWid, sim.tagColours[painted.tag])
+				if painted.tag != "" {
+					ebitenutil.DebugPrintAt(screen, painted.tag[:1], int(x)+2, int(y))
+				}
+			}
+
+			if sim.conflicts[[2]int{row, col}] {
+				drawOutline(screen, x, y, tileLen, tileWid, conflictColour)
+			}
+		}
+	}
+
+	cursorX, cursorY := ebiten.CursorPosition()
+	if row, col, ok := sim.cellAt(cursorX, cursorY); ok {
+		drawOutline(screen, tileLen*float64(row), tileWid*float64(col), tileLen, tileWid, cursorColour)
+	}
+
+	sim.drawPalette(screen)
+}
+
+// Draws the palette's entries that fit on screen, highlighting the selected brush
+func (sim *Simulation) drawPalette(screen *ebiten.Image) {
+	left := sim.screenWidth - paletteWidth
+	ebitenutil.DrawRect(screen, float64(left), 0, paletteWidth, float64(sim.screenHeight), paletteBackground)
+
+	maxChars := (paletteWidth - paletteIconSize - 12) / debugCharWidth
+	for entry := sim.paletteScroll; entry < len(sim.palette); entry++ {
+		y := (entry - sim.paletteScroll) * paletteEntryHeight
+		if y+paletteEntryHeight > sim.screenHeight {
+			break
+		}
+
+		if entry == sim.selected {
+			ebitenutil.DrawRect(screen, float64(left), float64(y), paletteWidth, paletteEntryHeight, selectedColour)
+		}
+
+		b := sim.palette[entry]
+		iconX, iconY := float64(left+4), float64(y+(paletteEntryHeight-paletteIconSize)/2)
+		switch b.kind {
+		case brushTile:
+			sim.drawTile(screen, b.tileId, iconX, iconY, paletteIconSize, paletteIconSize, 1)
+		case brushTag:
+			ebitenutil.DrawRect(screen, iconX, iconY, paletteIconSize, paletteIconSize, sim.tagColours[b.tag])
+		}
+
+		label := sim.brushName(b)
+		if len(label) > maxChars {
+			label = label[:maxChars-3] + "..."
+		}
+		ebitenutil.DebugPrintAt(screen, label, left+paletteIconSize+8, y+(paletteEntryHeight-debugLineHeight)/2)
+	}
+}
+
+// Returns the label of a brush in the palette and status line
+func (sim *Simulation) brushName(b brush) string {
+	switch b.kind {
+	case brushTile:
+		return sim.tileNames[b.tileId]
+	case brushTag:
+		return fmt.Sprintf("tag %s (%d tiles)", b.tag, len(sim.tagIds[b.tag]))
+	default:
+		return "erase"
+	}
+}
+
+// Draws the border of a rectangle
+func drawOutline(screen *ebiten.Image, x, y, width, height float64, clr color.Color) {
+	ebitenutil.DrawRect(screen, x, y, width, outlineWidth, clr)
+	ebitenutil.DrawRect(screen, x, y+height-outlineWidth, width, outlineWidth, clr)
+	ebitenutil.DrawRect(screen, x, y, outlineWidth, height, clr)
+	ebitenutil.DrawRect(screen, x+width-outlineWidth, y, outlineWidth, height, clr)
+}
@@ -0,0 +1,183 @@
+package imageprocess
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Characters used for connector strings, one per distinct edge colour found in the tileset
+const connectorChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// ExtractOptions controls how tile edges are turned into connector strings
+type ExtractOptions struct {
+	Samples   int // points sampled along each edge, the length of every connector string
+	Tolerance int // maximum difference in any colour channel (0-255) for two samples to be the same connector
+}
+
+// Samples the edges of every PNG and atlas tile in dirPath and writes a config for them, so a folder of images is a usable tileset
+// Atlases come from the settings of the config being replaced, or from atlas.json if there's no config yet
+// Refuses to replace an existing config unless overwrite is set, a replaced config keeps its format
+func ExtractDir(dirPath string, opts ExtractOptions, overwrite bool) error {
+	ts := tileset.New(dirPath, nil)
+	if configPath, _, err := tileset.FindConfig(dirPath); err == nil {
+		if !overwrite {
+			return fmt.Errorf("%s already exists, pass overwrite to replace it", configPath)
+		}
+
+		existing, err := tileset.Load(dirPath)
+		if err != nil {
+			return err
+		}
+		ts.Settings = existing.Settings
+		ts.SetFormat(existing.Format())
+	} else if errors.Is(err, os.ErrNotExist) {
+		atlases, err := tileset.LoadAtlases(dirPath)
+		if err != nil {
+			return err
+		}
+		ts.Settings.Atlases = atlases
+	} else {
+		return err
+	}
+
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return err
+	}
+
+	isAtlas := make(map[string]bool, len(ts.Settings.Atlases))
+	for _, atlas := range ts.Settings.Atlases {
+		isAtlas[atlas.Image] = true
+	}
+
+	// Every PNG is a tile, apart from atlases which are sliced into their tiles
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && !isAtlas[entry.Name()] && strings.EqualFold(path.Ext(entry.Name()), ".png") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	loader := ts.Loader()
+	for _, atlas := range ts.Settings.Atlases {
+		img, err := loader.BaseImage(atlas.Image)
+		if err != nil {
+			return err
+		}
+		names = append(names, atlas.TileNames(img.Bounds())...)
+	}
+
+	if len(names) == 0 {
+		return fmt.Errorf("no PNG tiles found in %s", dirPath)
+	}
+
+	images := make([]image.Image, len(names))
+	for idx, name := range names {
+		images[idx], err = loader.BaseImage(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	connections, err := ExtractConnectors(images, opts)
+	if err != nil {
+		return err
+	}
+
+	ts.Tiles = make([]tileset.Tile, len(names))
+	for idx, name := range names {
+		ts.Tiles[idx] = tileset.Tile{Name: name, Connections: connections[idx]}
+	}
+
+	return ts.Save(dirPath)
+}
+
+// Derives the connections of each image from the colours along its edges
+// Edges are read clockwise (up left to right, right top to bottom, down right to left, left bottom to top)
+// so touching edges read in opposite directions, which is what wfc's matching expects
+// Colours are shared across all the images, so the same colour gets the same character on every tile
+func ExtractConnectors(images []image.Image, opts ExtractOptions) ([]map[int]string, error) {
+	if opts.Samples <= 0 {
+		return nil, fmt.Errorf("sample count must be positive, got %d", opts.Samples)
+	}
+
+	palette := make([]color.NRGBA, 0, len(connectorChars))
+	// Returns the character for a colour, adding it to the palette if nothing within tolerance exists
+	connectorFunc := func(clr color.Color) (byte, error) {
+		sample := color.NRGBAModel.Convert(clr).(color.NRGBA)
+		for idx, known := range palette {
+			if coloursMatch(known, sample, opts.Tolerance) {
+				return connectorChars[idx], nil
+			}
+		}
+
+		if len(palette) == len(connectorChars) {
+			return 0, fmt.Errorf("more than %d distinct edge colours, increase the tolerance", len(connectorChars))
+		}
+		palette = append(palette, sample)
+		return connectorChars[len(palette)-1], nil
+	}
+
+	connections := make([]map[int]string, len(images))
+	for idx, img := range images {
+		connections[idx] = make(map[int]string, 4)
+		for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+			connector := make([]byte, opts.Samples)
+			for sample := range connector {
+				x, y := edgePoint(img.Bounds(), dir, sample, opts.Samples)
+				char, err := connectorFunc(img.At(x, y))
+				if err != nil {
+					return nil, err
+				}
+				connector[sample] = char
+			}
+			connections[idx][dir] = string(connector)
+		}
+	}
+
+	return connections, nil
+}
+
+// Returns the pixel for a sample along an edge, samples sit in the middle of equal segments of the edge
+func edgePoint(bounds image.Rectangle, dir, sample, samples int) (int, int) {
+	width, height := bounds.Dx(), bounds.Dy()
+	along := func(length int) int {
+		return (2*sample + 1) * length / (2 * samples)
+	}
+
+	var x, y int
+	switch dir {
+	case wfc.UP:
+		x, y = along(width), 0
+	case wfc.RIGHT:
+		x, y = width-1, along(height)
+	case wfc.DOWN:
+		x, y = width-1-along(width), height-1
+	case wfc.LEFT:
+		x, y = 0, height-1-along(height)
+	}
+
+	return bounds.Min.X + x, bounds.Min.Y + y
+}
+
+// Reports if every channel of the two colours is within the tolerance
+func coloursMatch(a, b color.NRGBA, tolerance int) bool {
+	diff := func(x, y uint8) int {
+		if x > y {
+			return int(x - y)
+		}
+		return int(y - x)
+	}
+
+	return diff(a.R, b.R) <= tolerance && diff(a.G, b.G) <= tolerance &&
+		diff(a.B, b.B) <= tolerance && diff(a.A, b.A) <= tolerance
+}
@@ -0,0 +1,70 @@
+package imageprocess
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"wavefunctioncollapse/wfc"
+)
+
+func Test_ExtractConnectors(t *testing.T) {
+	black := color.NRGBA{0, 0, 0, 255}
+	white := color.NRGBA{255, 255, 255, 255}
+	nearlyBlack := color.NRGBA{4, 4, 4, 255}
+
+	// Black tile with a white line from the top edge to the centre
+	line := image.NewNRGBA(image.Rect(0, 0, 9, 9))
+	for x := 0; x < 9; x++ {
+		for y := 0; y < 9; y++ {
+			line.Set(x, y, black)
+		}
+	}
+	for y := 0; y < 5; y++ {
+		line.Set(4, y, white)
+	}
+
+	// Solid tile in a colour close enough to black to share its connector
+	solid := image.NewNRGBA(image.Rect(0, 0, 9, 9))
+	for x := 0; x < 9; x++ {
+		for y := 0; y < 9; y++ {
+			solid.Set(x, y, nearlyBlack)
+		}
+	}
+
+	connections, err := ExtractConnectors([]image.Image{line, solid}, ExtractOptions{Samples: 3, Tolerance: 8})
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	expected := []map[int]string{
+		{wfc.LEFT: "AAA", wfc.UP: "ABA", wfc.RIGHT: "AAA", wfc.DOWN: "AAA"},
+		{wfc.LEFT: "AAA", wfc.UP: "AAA", wfc.RIGHT: "AAA", wfc.DOWN: "AAA"},
+	}
+	for idx := range expected {
+		for dir, connector := range expected[idx] {
+			if connections[idx][dir] != connector {
+				t.Errorf("Failed, tile %v direction %v expected %v, got %v", idx, dir, connector, connections[idx][dir])
+			}
+		}
+	}
+}
+
+func Test_edgePoint_clockwise(t *testing.T) {
+	bounds := image.Rect(0, 0, 10, 10)
+	testCases := []struct {
+		dir      int
+		expected image.Point
+	}{
+		{wfc.UP, image.Point{1, 0}},
+		{wfc.RIGHT, image.Point{9, 1}},
+		{wfc.DOWN, image.Point{8, 9}},
+		{wfc.LEFT, image.Point{0, 8}},
+	}
+
+	for _, tc := range testCases {
+		x, y := edgePoint(bounds, tc.dir, 0, 5)
+		if (image.Point{x, y}) != tc.expected {
+			t.Errorf("Failed, direction %v expected %v, got %v", tc.dir, tc.expected, image.Point{x, y})
+		}
+	}
+}
@@ -1,126 +1,112 @@
 package imageprocess
 
 import (
-	"encoding/json"
 	"fmt"
-	"image"
 	"os"
 	"path"
-	"reflect"
-	"wavefunctioncollapse/wfc"
-
-	"github.com/disintegration/imaging"
+	"path/filepath"
+	"wavefunctioncollapse/tileset"
 )
 
-type ConfigTile struct {
-	Name        string         `json:"name"`
-	Connections map[int]string `json:"connections"`
-}
+// Marks a directory as output of ProcessDir, so it's never processed again or overwritten unless it was generated
+const generatedMarker = ".wfc-generated"
 
-var directory string
+// Reads the tileset in dirPath and writes a config with the distinct rotated and mirrored variants of each tile to outPath
+// Variants reference the original images with a transform, so no images are written
+// dirPath is never modified, and outPath is replaced on every run so processing can be re-run safely
+func ProcessDir(dirPath, outPath string) error {
+	if path.Clean(dirPath) == path.Clean(outPath) {
+		return fmt.Errorf("output directory %s must be different to the tileset directory", outPath)
+	}
 
-func ProcessDir(dirPath string) {
-	directory = dirPath
-	configPath := path.Join(dirPath, "config.json")
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		panic(fmt.Errorf("failed to read file %s with err %v", configPath, err))
+	if isGenerated(dirPath) {
+		return fmt.Errorf("%s has already been processed, run against the original tileset instead", dirPath)
 	}
 
-	var config []ConfigTile
-	err = json.Unmarshal(data, &config)
+	ts, err := tileset.Load(dirPath)
 	if err != nil {
-		panic(fmt.Errorf("failed to marshal data with err %v", err))
+		return err
 	}
 
-	for _, tile := range config {
-		config = appendIfNonDuplicate(config, mutateImage(tile, "R"))
-		config = appendIfNonDuplicate(config, mutateImage(tile, "RR"))
-		config = appendIfNonDuplicate(config, mutateImage(tile, "RRR"))
-		config = appendIfNonDuplicate(config, mutateImage(tile, "F"))
-		config = appendIfNonDuplicate(config, mutateImage(tile, "FR"))
-		config = appendIfNonDuplicate(config, mutateImage(tile, "FRR"))
-		config = appendIfNonDuplicate(config, mutateImage(tile, "FRRR"))
+	if err := resetOutput(outPath); err != nil {
+		return err
 	}
 
-	data, err = json.Marshal(config)
+	// Images are referenced from the output directory, so point back to the originals
+	imgDir, err := filepath.Rel(outPath, dirPath)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	os.WriteFile(configPath, data, 0777)
-}
 
-func appendIfNonDuplicate(tiles []ConfigTile, toAppend ConfigTile) []ConfigTile {
-	valid := true
-	for _, tile := range tiles {
-		if reflect.DeepEqual(tile.Connections, toAppend.Connections) {
-			valid = false
+	loader := ts.Loader()
+	processed := make([]tileset.Tile, 0, len(ts.Tiles)*8)
+	for _, tile := range ts.Tiles {
+		img, err := loader.Image(tile)
+		if err != nil {
+			return err
 		}
-	}
 
-	if !valid {
-		// remove file if not valid
-		os.Remove(path.Join(directory, toAppend.Name))
-		return tiles
-	}
+		ops, err := orientations(tile, img)
+		if err != nil {
+			return err
+		}
 
-	tiles = append(tiles, toAppend)
-	return tiles
-}
+		tile.Name = filepath.ToSlash(filepath.Join(imgDir, tile.Name))
+		tile.Variants = nil
+		for _, op := range ops {
+			variant, err := tile.Transformed(op)
+			if err != nil {
+				return err
+			}
 
-func mutateImage(conf ConfigTile, op string) ConfigTile {
-	imgPath := path.Join(directory, conf.Name)
-	imgReader, err := os.Open(imgPath)
-	if err != nil {
-		panic(fmt.Errorf("failed to open image %s with error %v", imgPath, err))
+			processed = append(processed, variant)
+		}
 	}
-	defer imgReader.Close()
-	img, _, err := image.Decode(imgReader)
-	if err != nil {
-		panic(fmt.Errorf("failed to decode image %s with error %v", imgPath, err))
+
+	// Atlases are referenced from the output directory too
+	settings := ts.Settings
+	settings.Atlases = make([]tileset.Atlas, len(ts.Settings.Atlases))
+	for idx, atlas := range ts.Settings.Atlases {
+		atlas.Image = filepath.ToSlash(filepath.Join(imgDir, atlas.Image))
+		settings.Atlases[idx] = atlas
 	}
 
-	for _, char := range op {
-		switch char {
-		case 'R':
-			// god knows why, but the rotation is counter-clockwise
-			img = imaging.Rotate270(img)
-
-			conf.Connections = map[int]string{
-				wfc.LEFT:  conf.Connections[wfc.DOWN],
-				wfc.UP:    conf.Connections[wfc.LEFT],
-				wfc.RIGHT: conf.Connections[wfc.UP],
-				wfc.DOWN:  conf.Connections[wfc.RIGHT],
-			}
-		case 'F':
-			img = imaging.FlipH(img)
-			img = imaging.FlipV(img)
-
-			conf.Connections = map[int]string{
-				wfc.LEFT:  conf.Connections[wfc.RIGHT],
-				wfc.UP:    conf.Connections[wfc.DOWN],
-				wfc.RIGHT: conf.Connections[wfc.LEFT],
-				wfc.DOWN:  conf.Connections[wfc.UP],
-			}
-		default:
-			panic(fmt.Errorf("unsupported char %c", char))
-		}
+	out := tileset.New(outPath, processed)
+	out.Settings = settings
+	if err := out.Save(outPath); err != nil {
+		return err
 	}
 
-	newName := conf.Name[:len(conf.Name)-len(path.Ext(conf.Name))]
-	newName = newName + "-" + op + ".png"
-	newPath := path.Join(directory, newName)
+	return markGenerated(outPath)
+}
 
-	imaging.Save(img, newPath)
-	conf.Name = newName
+// Marks a directory as generated, written last so a failed run is never mistaken for a finished one
+func markGenerated(outPath string) error {
+	return os.WriteFile(path.Join(outPath, generatedMarker), []byte("generated by wavefunctioncollapse, do not edit\n"), 0644)
+}
 
-	return conf
+// Reports if the directory was written by ProcessDir
+func isGenerated(dirPath string) bool {
+	_, err := os.Stat(path.Join(dirPath, generatedMarker))
+	return err == nil
 }
 
-func reverse(s string) string {
-	runes := []rune(s)
-	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-		runes[i], runes[j] = runes[j], runes[i]
+// Clears a previously generated output directory, refusing to touch directories that weren't generated
+func resetOutput(outPath string) error {
+	entries, err := os.ReadDir(outPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
 	}
-	return string(runes)
+
+	if len(entries) > 0 {
+		if !isGenerated(outPath) {
+			return fmt.Errorf("output directory %s isn't empty and wasn't generated by a previous run", outPath)
+		}
+
+		if err := os.RemoveAll(outPath); err != nil {
+			return err
+		}
+	}
+
+	return os.MkdirAll(outPath, 0755)
 }
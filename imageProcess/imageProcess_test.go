@@ -0,0 +1,128 @@
+package imageprocess
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"wavefunctioncollapse/tileset"
+
+	"github.com/disintegration/imaging"
+)
+
+// Returns a tileset of an asymmetric tile and a blank one, which processing gives several variants
+func processSource(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	corner := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	corner.Set(1, 0, color.White)
+	corner.Set(1, 1, color.White)
+	corner.Set(2, 1, color.White)
+	for name, img := range map[string]image.Image{"corner.png": corner, "blank.png": image.NewNRGBA(image.Rect(0, 0, 3, 3))} {
+		if err := imaging.Save(img, path.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := []tileset.Tile{
+		{Name: "blank.png", Connections: map[int]string{0: "AAA", 1: "AAA", 2: "AAA", 3: "AAA"}},
+		{Name: "corner.png", Connections: map[int]string{0: "AAA", 1: "ABA", 2: "ABA", 3: "AAA"}},
+	}
+	if err := tileset.New(dir, config).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// Returns the contents of every file under dir, keyed by their path relative to it
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		files[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func Test_ProcessDir_rerun(t *testing.T) {
+	src := processSource(t)
+	out := path.Join(t.TempDir(), "generated")
+	original := readTree(t, src)
+
+	if err := ProcessDir(src, out); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	first := readTree(t, out)
+	if err := ProcessDir(src, out); err != nil {
+		t.Fatalf("Failed, expected no error running again, got %v", err)
+	}
+
+	if again := readTree(t, out); !reflect.DeepEqual(first, again) {
+		t.Errorf("Failed, expected the same output from both runs, got %v and %v", first, again)
+	}
+	if _, ok := first[generatedMarker]; !ok {
+		t.Errorf("Failed, expected the output to be marked as generated, got %v", first)
+	}
+	if got := readTree(t, src); !reflect.DeepEqual(original, got) {
+		t.Errorf("Failed, expected the source to be unchanged, got %v", got)
+	}
+
+	ts, err := tileset.Load(out)
+	if err != nil || len(ts.Tiles) != 5 {
+		t.Errorf("Failed, expected the blank tile and 4 corner variants, got %v with err %v", ts, err)
+	}
+}
+
+func Test_ProcessDir_refuses(t *testing.T) {
+	src := processSource(t)
+
+	t.Run("Output not generated", func(t *testing.T) {
+		out := t.TempDir()
+		keep := path.Join(out, "keep.txt")
+		if err := os.WriteFile(keep, []byte("mine"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ProcessDir(src, out); err == nil {
+			t.Errorf("Failed, expected an error for an output directory without the marker")
+		}
+		if _, err := os.Stat(keep); err != nil {
+			t.Errorf("Failed, expected the existing file to be kept, got %v", err)
+		}
+	})
+
+	t.Run("Generated input", func(t *testing.T) {
+		out := path.Join(t.TempDir(), "generated")
+		if err := ProcessDir(src, out); err != nil {
+			t.Fatalf("Failed, expected no error, got %v", err)
+		}
+		if err := ProcessDir(out, path.Join(t.TempDir(), "again")); err == nil {
+			t.Errorf("Failed, expected an error processing generated output")
+		}
+	})
+
+	t.Run("Same directory", func(t *testing.T) {
+		if err := ProcessDir(src, src); err == nil {
+			t.Errorf("Failed, expected an error writing over the source")
+		}
+	})
+
+	if _, err := tileset.Load(src); err != nil {
+		t.Errorf("Failed, expected the source to still load, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package imageprocess
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"path"
+	"wavefunctioncollapse/tileset"
+
+	"github.com/disintegration/imaging"
+)
+
+// Draws every tile in dirPath's config, with its transform applied, into a single atlas in outPath
+// The config written alongside refers to the atlas, so the packed tileset needs no transforms
+// Columns sets the width of the atlas in tiles, 0 makes it roughly square
+func PackDir(dirPath, outPath string, columns int) error {
+	if path.Clean(dirPath) == path.Clean(outPath) {
+		return fmt.Errorf("output directory %s must be different to the tileset directory", outPath)
+	}
+
+	ts, err := tileset.Load(dirPath)
+	if err != nil {
+		return err
+	}
+
+	atlasImg, atlas, err := PackImages(ts, "atlas.png", columns)
+	if err != nil {
+		return err
+	}
+
+	packed := make([]tileset.Tile, len(ts.Tiles))
+	for idx, tile := range ts.Tiles {
+		// Everything but the image carries over, the transform is already drawn into the atlas
+		tile.Name = fmt.Sprintf("%s#%d", atlas.Image, idx)
+		tile.Symmetry = ""
+		tile.Variants = nil
+		tile.Transform = ""
+		packed[idx] = tile
+	}
+
+	if err := resetOutput(outPath); err != nil {
+		return err
+	}
+
+	atlasPath := path.Join(outPath, atlas.Image)
+	if err := imaging.Save(atlasImg, atlasPath); err != nil {
+		return fmt.Errorf("failed to save image %s with error %v", atlasPath, err)
+	}
+
+	out := tileset.New(outPath, packed)
+	out.Settings = ts.Settings
+	out.Settings.Atlases = []tileset.Atlas{atlas}
+	if err := out.Save(outPath); err != nil {
+		return err
+	}
+
+	return markGenerated(outPath)
+}
+
+// Draws every tile in the tileset, with its transform applied, into an atlas image named atlasName
+// Tile IDs are the atlas indexes. Columns sets the width of the atlas in tiles, 0 makes it roughly square
+func PackImages(ts *tileset.Tileset, atlasName string, columns int) (*image.NRGBA, tileset.Atlas, error) {
+	config := ts.Tiles
+	if len(config) == 0 {
+		return nil, tileset.Atlas{}, fmt.Errorf("no tiles to pack in %s", ts.Dir())
+	}
+
+	loader := ts.Loader()
+
+	images := make([]image.Image, len(config))
+	for idx, tile := range config {
+		var err error
+		images[idx], err = loader.Image(tile)
+		if err != nil {
+			return nil, tileset.Atlas{}, err
+		}
+
+		if images[idx].Bounds().Size() != images[0].Bounds().Size() {
+			return nil, tileset.Atlas{}, fmt.Errorf("tile %s is %v, every tile must be the same size as %s (%v) to pack",
+				tile.Name, images[idx].Bounds().Size(), config[0].Name, images[0].Bounds().Size())
+		}
+	}
+
+	if columns <= 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(config)))))
+	}
+	rows := (len(config) + columns - 1) / columns
+
+	atlas := tileset.Atlas{
+		Image:      atlasName,
+		TileWidth:  images[0].Bounds().Dx(),
+		TileHeight: images[0].Bounds().Dy(),
+	}
+	atlasImg := image.NewNRGBA(image.Rect(0, 0, columns*atlas.TileWidth, rows*atlas.TileHeight))
+	for idx := range config {
+		rect, err := atlas.TileRect(atlasImg.Bounds(), idx)
+		if err != nil {
+			return nil, tileset.Atlas{}, err
+		}
+		draw.Draw(atlasImg, rect, images[idx], images[idx].Bounds().Min, draw.Src)
+	}
+
+	return atlasImg, atlas, nil
+}
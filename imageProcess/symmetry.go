@@ -0,0 +1,60 @@
+package imageprocess
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"wavefunctioncollapse/tileset"
+
+	"github.com/disintegration/imaging"
+)
+
+// Operations producing each distinct orientation of a tile, keyed by symmetry class
+// Classes follow the reference implementation, named after the letter with the same symmetry
+var symmetryOps = map[string][]tileset.Transform{
+	"X":  {""},                                             // symmetric under every rotation and mirror
+	"I":  {"", "R"},                                        // straight line, two orientations
+	"\\": {"", "R"},                                        // diagonal, two orientations
+	"L":  {"", "R", "RR", "RRR"},                           // corner, mirroring is the same as rotating
+	"T":  {"", "R", "RR", "RRR"},                           // t-junction, mirroring is the same as rotating
+	"F":  {"", "R", "RR", "RRR", "H", "HR", "HRR", "HRRR"}, // no symmetry, every rotation and mirror is distinct
+}
+
+// Returns the operations producing the distinct orientations of a tile
+// Uses the tile's declared variants or symmetry class, otherwise compares the pixels of every rotation and mirror
+func orientations(tile tileset.Tile, img image.Image) ([]tileset.Transform, error) {
+	if len(tile.Variants) > 0 {
+		return tile.Variants, nil
+	}
+
+	if tile.Symmetry != "" {
+		ops, ok := symmetryOps[tile.Symmetry]
+		if !ok {
+			return nil, fmt.Errorf("tile %s has unknown symmetry %q, expected one of X, I, \\, L, T or F", tile.Name, tile.Symmetry)
+		}
+		return ops, nil
+	}
+
+	ops := make([]tileset.Transform, 0, 8)
+	seen := make([]*image.NRGBA, 0, 8)
+	for _, op := range symmetryOps["F"] {
+		pixels := imaging.Clone(op.Apply(img))
+		if !containsImage(seen, pixels) {
+			ops = append(ops, op)
+			seen = append(seen, pixels)
+		}
+	}
+
+	return ops, nil
+}
+
+// Reports if any of the images has exactly the same pixels as img
+func containsImage(images []*image.NRGBA, img *image.NRGBA) bool {
+	for _, other := range images {
+		if other.Rect.Size() == img.Rect.Size() && bytes.Equal(other.Pix, img.Pix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,63 @@
+package imageprocess
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"wavefunctioncollapse/tileset"
+)
+
+func Test_orientations_detected(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	newTile := func(pixels ...image.Point) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+		for _, pixel := range pixels {
+			img.Set(pixel.X, pixel.Y, white)
+		}
+		return img
+	}
+
+	testCases := []struct {
+		name     string
+		img      image.Image
+		expected int
+	}{
+		{"Blank tile, one orientation", newTile(), 1},
+		{"Straight line, two orientations", newTile(image.Point{1, 0}, image.Point{1, 1}, image.Point{1, 2}), 2},
+		{"Diagonal line, two orientations", newTile(image.Point{0, 0}, image.Point{1, 1}, image.Point{2, 2}), 2},
+		{"T-junction, four orientations", newTile(image.Point{0, 1}, image.Point{1, 1}, image.Point{2, 1}, image.Point{1, 0}), 4},
+		{"Asymmetric tile, eight orientations", newTile(image.Point{1, 0}, image.Point{1, 1}, image.Point{2, 1}, image.Point{2, 2}), 8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ops, err := orientations(tileset.Tile{Name: "tile.png"}, tc.img)
+			if err != nil {
+				t.Fatalf("Failed, expected no error, got %v", err)
+			}
+
+			if len(ops) != tc.expected {
+				t.Errorf("Failed, expected %v orientations, got %v", tc.expected, ops)
+			}
+		})
+	}
+}
+
+func Test_orientations_declared(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+
+	ops, err := orientations(tileset.Tile{Name: "tile.png", Symmetry: "L"}, img)
+	if err != nil || len(ops) != 4 {
+		t.Errorf("Failed, expected %v orientations, got %v with err %v", 4, ops, err)
+	}
+
+	ops, err = orientations(tileset.Tile{Name: "tile.png", Symmetry: "L", Variants: []tileset.Transform{"", "V"}}, img)
+	if err != nil || len(ops) != 2 || ops[1] != "V" {
+		t.Errorf("Failed, expected declared variants %v, got %v with err %v", []tileset.Transform{"", "V"}, ops, err)
+	}
+
+	_, err = orientations(tileset.Tile{Name: "tile.png", Symmetry: "Q"}, img)
+	if err == nil {
+		t.Errorf("Failed, expected error for unknown symmetry")
+	}
+}
@@ -0,0 +1,154 @@
+package imageprocess
+
+import (
+	"fmt"
+	"image"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/disintegration/imaging"
+)
+
+// Problem is something wrong with a tileset that would stop it generating or rendering properly
+type Problem struct {
+	Tile    string // the tile with the problem, empty if it's the whole tileset
+	Message string
+}
+
+func (problem Problem) String() string {
+	if problem.Tile == "" {
+		return problem.Message
+	}
+	return problem.Tile + ": " + problem.Message
+}
+
+// Checks the tileset in dirPath, returning every problem found
+// Returns an error only if the config itself can't be read
+func Validate(dirPath string) ([]Problem, error) {
+	ts, err := tileset.Load(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	config := ts.Tiles
+	loader := ts.Loader()
+
+	problems := make([]Problem, 0)
+	report := func(tile tileset.Tile, format string, args ...interface{}) {
+		problems = append(problems, Problem{Tile: tileLabel(tile), Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(config) <= 1 {
+		problems = append(problems, Problem{Message: fmt.Sprintf("tileset needs at least two tiles, found %d", len(config))})
+	}
+
+	// Images must exist and all be the same size, otherwise the tiles won't line up when drawn
+	images := make([]*image.NRGBA, len(config))
+	sizes := make(map[image.Point]int)
+	for idx, tile := range config {
+		img, err := loader.Image(tile)
+		if err != nil {
+			report(tile, "missing image, %v", err)
+			continue
+		}
+		images[idx] = imaging.Clone(img)
+		sizes[img.Bounds().Size()]++
+	}
+
+	commonSize, commonCount := image.Point{}, 0
+	for size, count := range sizes {
+		if count > commonCount || (count == commonCount && size.X*size.Y < commonSize.X*commonSize.Y) {
+			commonSize, commonCount = size, count
+		}
+	}
+	for idx, tile := range config {
+		if images[idx] != nil && images[idx].Rect.Size() != commonSize {
+			report(tile, "image is %dx%d, every other tile is %dx%d", images[idx].Rect.Dx(), images[idx].Rect.Dy(), commonSize.X, commonSize.Y)
+		}
+	}
+
+	// Connectors need all four directions, and to be the same length, as they're compared character by character
+	lengths := make(map[int]int)
+	for _, tile := range config {
+		for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+			if connector, ok := tile.Connections[dir]; ok {
+				lengths[len(connector)]++
+			}
+		}
+	}
+
+	commonLength := 0
+	commonCount = 0
+	for length, count := range lengths {
+		if count > commonCount || (count == commonCount && length < commonLength) {
+			commonLength, commonCount = length, count
+		}
+	}
+	for _, tile := range config {
+		for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+			connector, ok := tile.Connections[dir]
+			if !ok {
+				report(tile, "no connector for %s", tileset.DirectionNames[dir])
+			} else if len(connector) != commonLength {
+				report(tile, "%s connector %q is %d characters, every other connector is %d", tileset.DirectionNames[dir], connector, len(connector), commonLength)
+			}
+		}
+	}
+
+	// Identical tiles only skew how often a tile appears
+	for idx, tile := range config {
+		for prevIdx := 0; prevIdx < idx; prevIdx++ {
+			prev := config[prevIdx]
+			sameImage := (prev.Name == tile.Name && prev.Transform == tile.Transform) ||
+				(images[idx] != nil && images[prevIdx] != nil && containsImage([]*image.NRGBA{images[prevIdx]}, images[idx]))
+			if sameImage && sameConnections(prev, tile) {
+				report(tile, "duplicate of %s", tileLabel(prev))
+				break
+			}
+		}
+	}
+
+	// An edge no tile can sit against means the tile can only ever be placed with that edge on the border
+	tileSet := ts.WfcTiles()
+
+	for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+		anyMatch := false
+		for idx, tile := range tileSet {
+			matched := false
+			for _, other := range tileSet {
+				if wfc.Matches(dir, tile, other) {
+					matched = true
+					break
+				}
+			}
+
+			if !matched {
+				report(config[idx], "%s connector %q can't be matched by any tile", tileset.DirectionNames[dir], tile.Configuration[dir])
+			}
+			anyMatch = anyMatch || matched
+		}
+
+		if !anyMatch && len(tileSet) > 0 {
+			problems = append(problems, Problem{Message: fmt.Sprintf("no two tiles can sit next to each other %s, every direction needs at least one compatible pair", tileset.DirectionNames[dir])})
+		}
+	}
+
+	return problems, nil
+}
+
+// Returns the name of a tile along with its transform, so variants of the same image can be told apart
+func tileLabel(tile tileset.Tile) string {
+	if tile.Transform == "" {
+		return tile.Name
+	}
+	return tile.Name + " (" + string(tile.Transform) + ")"
+}
+
+// Reports if both tiles have exactly the same connectors
+func sameConnections(a, b tileset.Tile) bool {
+	for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+		if a.Connections[dir] != b.Connections[dir] {
+			return false
+		}
+	}
+	return true
+}
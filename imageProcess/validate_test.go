@@ -0,0 +1,62 @@
+package imageprocess
+
+import (
+	"image"
+	"path"
+	"strings"
+	"testing"
+	"wavefunctioncollapse/tileset"
+
+	"github.com/disintegration/imaging"
+)
+
+func Test_Validate(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{"a.png": 4, "b.png": 4, "big.png": 8} {
+		if err := imaging.Save(image.NewNRGBA(image.Rect(0, 0, size, size)), path.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := []tileset.Tile{
+		{Name: "a.png", Connections: map[int]string{0: "AAA", 1: "AAA", 2: "AAA", 3: "AAA"}},
+		{Name: "a.png", Connections: map[int]string{0: "AAA", 1: "AAA", 2: "AAA", 3: "AAA"}},
+		{Name: "b.png", Connections: map[int]string{0: "AAA", 1: "AAAA", 2: "AAA", 3: "AAA"}},
+		{Name: "big.png", Connections: map[int]string{0: "AAA", 1: "AAA", 2: "AAA", 3: "CCC"}},
+		{Name: "missing.png", Connections: map[int]string{0: "AAA", 1: "AAA", 2: "AAA"}},
+	}
+	if err := tileset.New(dir, config).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := Validate(dir)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	expected := []string{
+		"missing.png: missing image",
+		"big.png: image is 8x8",
+		"b.png: up connector \"AAAA\" is 4 characters",
+		"missing.png: no connector for down",
+		"a.png: duplicate of a.png",
+		"big.png: down connector \"CCC\" can't be matched",
+	}
+	for _, msg := range expected {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem.String(), msg)
+		}
+
+		if !found {
+			t.Errorf("Failed, expected problem %q, got %v", msg, problems)
+		}
+	}
+}
+
+func Test_Validate_assets(t *testing.T) {
+	problems, err := Validate("../assets")
+	if err != nil || len(problems) > 0 {
+		t.Errorf("Failed, expected assets to be valid, got %v with err %v", problems, err)
+	}
+}
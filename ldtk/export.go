@@ -0,0 +1,298 @@
+package ldtk
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	mathrand "math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	imageprocess "wavefunctioncollapse/imageProcess"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/disintegration/imaging"
+)
+
+// Range of the seeds LDtk gives layers
+const layerSeeds = 9999999
+
+// Characters LDtk doesn't allow in identifiers
+var identifierInvalid = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// Returns a project with a single level holding a generated result
+// Every tile, transforms applied, is packed into atlasImg, which the project expects at atlasPath relative to itself
+// The level has a Tiles layer drawing the map and an IntGrid layer with each tile's ID plus one, as 0 is empty in LDtk
+func NewProject(ts *tileset.Tileset, result [][]int, atlasPath string) (*Project, *image.NRGBA, error) {
+	if len(result) == 0 {
+		return nil, nil, fmt.Errorf("nothing to export, the map is empty")
+	}
+
+	// LDtk can only mirror tiles, so every variant is drawn into the atlas and the tile IDs line up with wfc's
+	atlasImg, atlas, err := imageprocess.PackImages(ts, filepath.Base(atlasPath), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if atlas.TileWidth != atlas.TileHeight {
+		return nil, nil, fmt.Errorf("tiles are %dx%d, LDtk needs square tiles", atlas.TileWidth, atlas.TileHeight)
+	}
+
+	gridSize := atlas.TileWidth
+	columns, rows := atlas.Grid(atlasImg.Bounds())
+	width, height := len(result), len(result[0])
+	tileRect := func(id int) TilesetRect {
+		return TilesetRect{TilesetUid: tilesetUid, X: id % columns * gridSize, Y: id / columns * gridSize, W: gridSize, H: gridSize}
+	}
+
+	tilesetDef := TilesetDef{
+		CWid:            columns,
+		CHei:            rows,
+		Identifier:      identifier(tilesetName(ts), "Tileset"),
+		Uid:             tilesetUid,
+		RelPath:         filepath.ToSlash(atlasPath),
+		PxWid:           atlasImg.Bounds().Dx(),
+		PxHei:           atlasImg.Bounds().Dy(),
+		TileGridSize:    gridSize,
+		Tags:            []string{},
+		EnumTags:        []interface{}{},
+		SavedSelections: []interface{}{},
+	}
+
+	values := make([]IntGridValue, len(ts.Tiles))
+	used := make(map[string]bool, len(ts.Tiles))
+	for id, tile := range ts.Tiles {
+		name := identifier(tile.Label(), "Tile")
+		if used[name] {
+			name = fmt.Sprintf("%s_%d", name, id)
+		}
+		used[name] = true
+
+		rect := tileRect(id)
+		values[id] = IntGridValue{Value: id + 1, Identifier: name, Color: valueColour(id, len(ts.Tiles)), Tile: &rect}
+		// Keep the tile's config name so tools reading the project can find it again
+		tilesetDef.CustomData = append(tilesetDef.CustomData, CustomData{TileId: id, Data: tile.Label()})
+	}
+
+	level := Level{
+		Identifier:        "Level_0",
+		Iid:               newIid(),
+		Uid:               levelUid,
+		PxWid:             width * gridSize,
+		PxHei:             height * gridSize,
+		BgColorDefault:    "#696A79",
+		UseAutoIdentifier: true,
+		BgPivotX:          0.5,
+		BgPivotY:          0.5,
+		SmartColor:        "#ADADB5",
+		FieldInstances:    []interface{}{},
+		Neighbours:        []interface{}{},
+	}
+
+	tilesDef := layerDef("Tiles", "Tiles", tilesLayerUid, gridSize)
+	tilesDef.TilesetDefUid = intPtr(tilesetUid)
+	idsDef := layerDef("Wfc_ids", "IntGrid", idsLayerUid, gridSize)
+	idsDef.IntGridValues = values
+
+	tilesLayer := newLayerInstance(tilesDef, width, height)
+	tilesLayer.TilesetDefUid, tilesLayer.TilesetRelPath = tilesDef.TilesetDefUid, &tilesetDef.RelPath
+	idsLayer := newLayerInstance(idsDef, width, height)
+	// The IDs are for code reading the project, the Tiles layer shows the map
+	idsLayer.Visible = false
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			id := result[x][y]
+			if id == wfc.Undecided {
+				idsLayer.IntGridCsv = append(idsLayer.IntGridCsv, 0)
+				continue
+			}
+			if id < 0 || id >= len(ts.Tiles) {
+				return nil, nil, fmt.Errorf("tile %d at (%d, %d) isn't in the tileset", id, x, y)
+			}
+
+			idsLayer.IntGridCsv = append(idsLayer.IntGridCsv, id+1)
+			rect := tileRect(id)
+			tilesLayer.GridTiles = append(tilesLayer.GridTiles, TileInstance{
+				Px:  [2]int{x * gridSize, y * gridSize},
+				Src: [2]int{rect.X, rect.Y},
+				T:   id,
+				D:   []int{y*width + x},
+				A:   1,
+			})
+		}
+	}
+	level.LayerInstances = []LayerInstance{tilesLayer, idsLayer}
+
+	project := &Project{
+		Header: Header{
+			FileType:   "LDtk Project JSON",
+			App:        "LDtk",
+			Doc:        "https://ldtk.io/json",
+			Schema:     "https://ldtk.io/files/JSON_SCHEMA.json",
+			AppAuthor:  "Sebastien 'deepnight' Benard",
+			AppVersion: jsonVersion,
+			Url:        "https://ldtk.io",
+		},
+		Iid:                 newIid(),
+		JsonVersion:         jsonVersion,
+		NextUid:             nextUid,
+		IdentifierStyle:     "Capitalize",
+		Toc:                 []interface{}{},
+		WorldLayout:         "Free",
+		WorldGridWidth:      level.PxWid,
+		WorldGridHeight:     level.PxHei,
+		DefaultLevelWidth:   level.PxWid,
+		DefaultLevelHeight:  level.PxHei,
+		DefaultGridSize:     gridSize,
+		DefaultEntityWidth:  gridSize,
+		DefaultEntityHeight: gridSize,
+		BgColor:             "#40465B",
+		DefaultLevelBgColor: "#696A79",
+		ImageExportMode:     "None",
+		ExportLevelBg:       true,
+		BackupLimit:         10,
+		LevelNamePattern:    "Level_%idx",
+		CustomCommands:      []interface{}{},
+		Flags:               []string{},
+		Defs: Defs{
+			// Listed top layer first, the same as the level's instances
+			Layers:        []LayerDef{tilesDef, idsDef},
+			Entities:      []interface{}{},
+			Tilesets:      []TilesetDef{tilesetDef},
+			Enums:         []interface{}{},
+			ExternalEnums: []interface{}{},
+			LevelFields:   []interface{}{},
+		},
+		Levels:        []Level{level},
+		Worlds:        []interface{}{},
+		DummyWorldIid: newIid(),
+	}
+
+	return project, atlasImg, nil
+}
+
+// Writes a generated result to an .ldtk project, with its tileset image written next to it as <project name>_tileset.png
+func WriteProject(ts *tileset.Tileset, result [][]int, ldtkPath string) error {
+	atlasPath := strings.TrimSuffix(filepath.Base(ldtkPath), filepath.Ext(ldtkPath)) + "_tileset.png"
+	project, atlasImg, err := NewProject(ts, result, atlasPath)
+	if err != nil {
+		return err
+	}
+
+	imgPath := filepath.Join(filepath.Dir(ldtkPath), atlasPath)
+	if err := imaging.Save(atlasImg, imgPath); err != nil {
+		return fmt.Errorf("failed to save image %s with error %v", imgPath, err)
+	}
+
+	data, err := json.MarshalIndent(project, "", "\t")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s with error %v", ldtkPath, err)
+	}
+	if err := os.WriteFile(ldtkPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s with error %v", ldtkPath, err)
+	}
+	return nil
+}
+
+// Returns the definition of a layer with LDtk's defaults
+func layerDef(name, layerType string, uid, gridSize int) LayerDef {
+	return LayerDef{
+		Type:                  layerType,
+		Identifier:            name,
+		LayerType:             layerType,
+		Uid:                   uid,
+		GridSize:              gridSize,
+		DisplayOpacity:        1,
+		InactiveOpacity:       1,
+		CanSelectWhenInactive: true,
+		RenderInWorldView:     true,
+		ParallaxScaling:       true,
+		RequiredTags:          []string{},
+		ExcludedTags:          []string{},
+		IntGridValues:         []IntGridValue{},
+		IntGridValuesGroups:   []interface{}{},
+		AutoRuleGroups:        []interface{}{},
+	}
+}
+
+// Returns an empty instance of a layer in the level
+func newLayerInstance(def LayerDef, width, height int) LayerInstance {
+	return LayerInstance{
+		Identifier:      def.Identifier,
+		Type:            def.LayerType,
+		CWid:            width,
+		CHei:            height,
+		GridSize:        def.GridSize,
+		Opacity:         1,
+		Iid:             newIid(),
+		LevelId:         levelUid,
+		LayerDefUid:     def.Uid,
+		Visible:         true,
+		OptionalRules:   []interface{}{},
+		IntGridCsv:      []int{},
+		AutoLayerTiles:  []interface{}{},
+		Seed:            mathrand.Int63n(layerSeeds), // only used by auto layers, LDtk keeps it small
+		GridTiles:       []TileInstance{},
+		EntityInstances: []interface{}{},
+	}
+}
+
+// Returns the name of the LDtk tileset, the tileset's name or its directory
+func tilesetName(ts *tileset.Tileset) string {
+	if ts.Settings.Name != "" {
+		return ts.Settings.Name
+	}
+	return filepath.Base(ts.Dir())
+}
+
+// Returns name as a valid LDtk identifier, capitalised with invalid characters replaced by underscores
+func identifier(name, fallback string) string {
+	name = strings.Trim(identifierInvalid.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = fallback
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		name = fallback + "_" + name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// Returns a distinct colour for each IntGrid value, spread around the hue wheel
+func valueColour(id, count int) string {
+	hue := float64(id) / float64(count) * 6
+	x := 1 - math.Abs(math.Mod(hue, 2)-1)
+	var r, g, b float64
+	switch int(hue) {
+	case 0:
+		r, g = 1, x
+	case 1:
+		r, g = x, 1
+	case 2:
+		g, b = 1, x
+	case 3:
+		g, b = x, 1
+	case 4:
+		r, b = x, 1
+	default:
+		r, b = 1, x
+	}
+	return fmt.Sprintf("#%02X%02X%02X", int(r*200+55), int(g*200+55), int(b*200+55))
+}
+
+// Returns a random UUID, LDtk identifies instances by them
+func newIid() string {
+	var uuid [16]byte
+	if _, err := rand.Read(uuid[:]); err != nil {
+		panic(fmt.Errorf("failed to generate iid with error %v", err))
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40 // version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
+}
+
+func intPtr(value int) *int {
+	return &value
+}
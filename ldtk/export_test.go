@@ -0,0 +1,93 @@
+package ldtk
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+func Test_NewProject(t *testing.T) {
+	ts, err := tileset.Load("../assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	result := [][]int{{0, 4}, {wfc.Undecided, 3}, {2, 1}}
+	project, atlasImg, err := NewProject(ts, result, "map.png")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	// Five 60 pixel tiles pack into a 3x2 atlas
+	tilesetDef := project.Defs.Tilesets[0]
+	if tilesetDef.CWid != 3 || tilesetDef.CHei != 2 || tilesetDef.PxWid != atlasImg.Bounds().Dx() || tilesetDef.TileGridSize != 60 {
+		t.Errorf("Failed, expected a 3x2 tileset of 60 pixel tiles, got %+v", tilesetDef)
+	}
+
+	layers := project.Levels[0].LayerInstances
+	expectedIds := []int{1, 0, 3, 5, 4, 2}
+	if !reflect.DeepEqual(expectedIds, layers[1].IntGridCsv) {
+		t.Errorf("Failed, expected IntGrid %v, got %v", expectedIds, layers[1].IntGridCsv)
+	}
+
+	if len(layers[0].GridTiles) != 5 {
+		t.Fatalf("Failed, expected a tile for every decided position, got %v", layers[0].GridTiles)
+	}
+	expectedTile := TileInstance{Px: [2]int{0, 60}, Src: [2]int{60, 60}, T: 4, D: []int{3}, A: 1}
+	if !reflect.DeepEqual(expectedTile, layers[0].GridTiles[2]) {
+		t.Errorf("Failed, expected %+v, got %+v", expectedTile, layers[0].GridTiles[2])
+	}
+
+	values := project.Defs.Layers[1].IntGridValues
+	if len(values) != len(ts.Tiles) || values[1].Identifier != "T_junction_up_png" || values[1].Value != 2 {
+		t.Errorf("Failed, expected a value named after each tile, got %+v", values)
+	}
+}
+
+func Test_WriteProject(t *testing.T) {
+	ts, err := tileset.Load("../assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	dir := t.TempDir()
+	ldtkPath := filepath.Join(dir, "level.ldtk")
+	if err := WriteProject(ts, [][]int{{0}}, ldtkPath); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "level_tileset.png")); err != nil {
+		t.Errorf("Failed, expected the tileset image next to the project, got %v", err)
+	}
+
+	data, err := os.ReadFile(ldtkPath)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	var project map[string]interface{}
+	if err := json.Unmarshal(data, &project); err != nil || project["jsonVersion"] != jsonVersion {
+		t.Errorf("Failed, expected an LDtk project, got %v with err %v", project["jsonVersion"], err)
+	}
+}
+
+func Test_identifier(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"blank.png", "Blank_png"},
+		{"tiles.png#3 (RH)", "Tiles_png_3_RH"},
+		{"3.png", "Tile_3_png"},
+		{"???", "Tile"},
+	}
+
+	for _, tc := range testCases {
+		if res := identifier(tc.name, "Tile"); res != tc.expected {
+			t.Errorf("Failed, %q expected %q, got %q", tc.name, tc.expected, res)
+		}
+	}
+}
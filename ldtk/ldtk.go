@@ -0,0 +1,216 @@
+package ldtk
+
+// Version of the LDtk project format written, see https://ldtk.io/json
+const jsonVersion = "1.5.3"
+
+// Unique IDs of the definitions in a project, LDtk keeps them in nextUid
+const (
+	tilesetUid = 1 + iota
+	tilesLayerUid
+	idsLayerUid
+	levelUid
+	nextUid
+)
+
+// Project is an LDtk project with a single level, only the fields this package writes are included
+type Project struct {
+	Header              Header        `json:"__header__"`
+	Iid                 string        `json:"iid"`
+	JsonVersion         string        `json:"jsonVersion"`
+	NextUid             int           `json:"nextUid"`
+	IdentifierStyle     string        `json:"identifierStyle"`
+	Toc                 []interface{} `json:"toc"`
+	WorldLayout         string        `json:"worldLayout"`
+	WorldGridWidth      int           `json:"worldGridWidth"`
+	WorldGridHeight     int           `json:"worldGridHeight"`
+	DefaultLevelWidth   int           `json:"defaultLevelWidth"`
+	DefaultLevelHeight  int           `json:"defaultLevelHeight"`
+	DefaultPivotX       float64       `json:"defaultPivotX"`
+	DefaultPivotY       float64       `json:"defaultPivotY"`
+	DefaultGridSize     int           `json:"defaultGridSize"`
+	DefaultEntityWidth  int           `json:"defaultEntityWidth"`
+	DefaultEntityHeight int           `json:"defaultEntityHeight"`
+	BgColor             string        `json:"bgColor"`
+	DefaultLevelBgColor string        `json:"defaultLevelBgColor"`
+	MinifyJson          bool          `json:"minifyJson"`
+	ExternalLevels      bool          `json:"externalLevels"`
+	ExportTiled         bool          `json:"exportTiled"`
+	SimplifiedExport    bool          `json:"simplifiedExport"`
+	ImageExportMode     string        `json:"imageExportMode"`
+	ExportLevelBg       bool          `json:"exportLevelBg"`
+	PngFilePattern      *string       `json:"pngFilePattern"`
+	BackupOnSave        bool          `json:"backupOnSave"`
+	BackupLimit         int           `json:"backupLimit"`
+	BackupRelPath       *string       `json:"backupRelPath"`
+	LevelNamePattern    string        `json:"levelNamePattern"`
+	TutorialDesc        *string       `json:"tutorialDesc"`
+	CustomCommands      []interface{} `json:"customCommands"`
+	Flags               []string      `json:"flags"`
+	Defs                Defs          `json:"defs"`
+	Levels              []Level       `json:"levels"`
+	Worlds              []interface{} `json:"worlds"`
+	DummyWorldIid       string        `json:"dummyWorldIid"`
+}
+
+// Header identifies the file as an LDtk project
+type Header struct {
+	FileType   string `json:"fileType"`
+	App        string `json:"app"`
+	Doc        string `json:"doc"`
+	Schema     string `json:"schema"`
+	AppAuthor  string `json:"appAuthor"`
+	AppVersion string `json:"appVersion"`
+	Url        string `json:"url"`
+}
+
+// Defs holds the layer and tileset definitions shared by every level
+type Defs struct {
+	Layers        []LayerDef    `json:"layers"`
+	Entities      []interface{} `json:"entities"`
+	Tilesets      []TilesetDef  `json:"tilesets"`
+	Enums         []interface{} `json:"enums"`
+	ExternalEnums []interface{} `json:"externalEnums"`
+	LevelFields   []interface{} `json:"levelFields"`
+}
+
+// LayerDef defines a Tiles or IntGrid layer
+type LayerDef struct {
+	Type                         string         `json:"__type"`
+	Identifier                   string         `json:"identifier"`
+	LayerType                    string         `json:"type"`
+	Uid                          int            `json:"uid"`
+	Doc                          *string        `json:"doc"`
+	UiColor                      *string        `json:"uiColor"`
+	GridSize                     int            `json:"gridSize"`
+	GuideGridWid                 int            `json:"guideGridWid"`
+	GuideGridHei                 int            `json:"guideGridHei"`
+	DisplayOpacity               float64        `json:"displayOpacity"`
+	InactiveOpacity              float64        `json:"inactiveOpacity"`
+	HideInList                   bool           `json:"hideInList"`
+	HideFieldsWhenInactive       bool           `json:"hideFieldsWhenInactive"`
+	CanSelectWhenInactive        bool           `json:"canSelectWhenInactive"`
+	RenderInWorldView            bool           `json:"renderInWorldView"`
+	PxOffsetX                    int            `json:"pxOffsetX"`
+	PxOffsetY                    int            `json:"pxOffsetY"`
+	ParallaxFactorX              float64        `json:"parallaxFactorX"`
+	ParallaxFactorY              float64        `json:"parallaxFactorY"`
+	ParallaxScaling              bool           `json:"parallaxScaling"`
+	RequiredTags                 []string       `json:"requiredTags"`
+	ExcludedTags                 []string       `json:"excludedTags"`
+	AutoTilesKilledByOtherLayers bool           `json:"autoTilesKilledByOtherLayers"`
+	IntGridValues                []IntGridValue `json:"intGridValues"`
+	IntGridValuesGroups          []interface{}  `json:"intGridValuesGroups"`
+	AutoRuleGroups               []interface{}  `json:"autoRuleGroups"`
+	AutoSourceLayerDefUid        *int           `json:"autoSourceLayerDefUid"`
+	TilesetDefUid                *int           `json:"tilesetDefUid"`
+	TilePivotX                   float64        `json:"tilePivotX"`
+	TilePivotY                   float64        `json:"tilePivotY"`
+	BiomeFieldUid                *int           `json:"biomeFieldUid"`
+}
+
+// IntGridValue names a value of an IntGrid layer, shown with its tile in the editor
+type IntGridValue struct {
+	Value      int          `json:"value"`
+	Identifier string       `json:"identifier"`
+	Color      string       `json:"color"`
+	Tile       *TilesetRect `json:"tile"`
+	GroupUid   int          `json:"groupUid"`
+}
+
+// TilesetRect is an area of a tileset image
+type TilesetRect struct {
+	TilesetUid int `json:"tilesetUid"`
+	X          int `json:"x"`
+	Y          int `json:"y"`
+	W          int `json:"w"`
+	H          int `json:"h"`
+}
+
+// TilesetDef is a tileset image sliced into a grid of tiles
+type TilesetDef struct {
+	CWid              int           `json:"__cWid"`
+	CHei              int           `json:"__cHei"`
+	Identifier        string        `json:"identifier"`
+	Uid               int           `json:"uid"`
+	RelPath           string        `json:"relPath"`
+	EmbedAtlas        *string       `json:"embedAtlas"`
+	PxWid             int           `json:"pxWid"`
+	PxHei             int           `json:"pxHei"`
+	TileGridSize      int           `json:"tileGridSize"`
+	Spacing           int           `json:"spacing"`
+	Padding           int           `json:"padding"`
+	Tags              []string      `json:"tags"`
+	TagsSourceEnumUid *int          `json:"tagsSourceEnumUid"`
+	EnumTags          []interface{} `json:"enumTags"`
+	CustomData        []CustomData  `json:"customData"`
+	SavedSelections   []interface{} `json:"savedSelections"`
+	CachedPixelData   interface{}   `json:"cachedPixelData"`
+}
+
+// CustomData is text attached to a tile of a tileset
+type CustomData struct {
+	TileId int    `json:"tileId"`
+	Data   string `json:"data"`
+}
+
+// Level is a single level of the project
+type Level struct {
+	Identifier        string          `json:"identifier"`
+	Iid               string          `json:"iid"`
+	Uid               int             `json:"uid"`
+	WorldX            int             `json:"worldX"`
+	WorldY            int             `json:"worldY"`
+	WorldDepth        int             `json:"worldDepth"`
+	PxWid             int             `json:"pxWid"`
+	PxHei             int             `json:"pxHei"`
+	BgColorDefault    string          `json:"__bgColor"`
+	BgColor           *string         `json:"bgColor"`
+	UseAutoIdentifier bool            `json:"useAutoIdentifier"`
+	BgRelPath         *string         `json:"bgRelPath"`
+	BgPos             *string         `json:"bgPos"`
+	BgPivotX          float64         `json:"bgPivotX"`
+	BgPivotY          float64         `json:"bgPivotY"`
+	SmartColor        string          `json:"__smartColor"`
+	BgPosInfo         interface{}     `json:"__bgPos"`
+	ExternalRelPath   *string         `json:"externalRelPath"`
+	FieldInstances    []interface{}   `json:"fieldInstances"`
+	LayerInstances    []LayerInstance `json:"layerInstances"`
+	Neighbours        []interface{}   `json:"__neighbours"`
+}
+
+// LayerInstance is the content of a layer in a level, LDtk lists them top layer first
+type LayerInstance struct {
+	Identifier      string         `json:"__identifier"`
+	Type            string         `json:"__type"`
+	CWid            int            `json:"__cWid"`
+	CHei            int            `json:"__cHei"`
+	GridSize        int            `json:"__gridSize"`
+	Opacity         float64        `json:"__opacity"`
+	PxTotalOffsetX  int            `json:"__pxTotalOffsetX"`
+	PxTotalOffsetY  int            `json:"__pxTotalOffsetY"`
+	TilesetDefUid   *int           `json:"__tilesetDefUid"`
+	TilesetRelPath  *string        `json:"__tilesetRelPath"`
+	Iid             string         `json:"iid"`
+	LevelId         int            `json:"levelId"`
+	LayerDefUid     int            `json:"layerDefUid"`
+	PxOffsetX       int            `json:"pxOffsetX"`
+	PxOffsetY       int            `json:"pxOffsetY"`
+	Visible         bool           `json:"visible"`
+	OptionalRules   []interface{}  `json:"optionalRules"`
+	IntGridCsv      []int          `json:"intGridCsv"`
+	AutoLayerTiles  []interface{}  `json:"autoLayerTiles"`
+	Seed            int64          `json:"seed"`
+	OverrideTileset *int           `json:"overrideTilesetUid"`
+	GridTiles       []TileInstance `json:"gridTiles"`
+	EntityInstances []interface{}  `json:"entityInstances"`
+}
+
+// TileInstance places a tile of the tileset in a Tiles layer
+type TileInstance struct {
+	Px  [2]int  `json:"px"`  // pixel position in the layer
+	Src [2]int  `json:"src"` // pixel position in the tileset image
+	F   int     `json:"f"`   // flip bits, 1 for x and 2 for y
+	T   int     `json:"t"`   // tile ID in the tileset
+	D   []int   `json:"d"`   // the cell the tile was placed in
+	A   float64 `json:"a"`   // opacity
+}
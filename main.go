@@ -1,46 +1,195 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	_ "image/png"
+	"io"
 	"log"
 	"os"
 	"runtime/pprof"
-	"wavefunctioncollapse/gui"
-	imageprocess "wavefunctioncollapse/imageProcess"
+	"strings"
+	"wavefunctioncollapse/tileset"
 )
 
-var (
-	width  = flag.Int("width", 32, "width of grid to collapse")
-	height = flag.Int("height", 18, "height of grid to collapse")
-	dir    = flag.String("directory", "", "directory of tiles with config to run against")
-
-	process = flag.String("process", "", "directory of tiles to process ")
+// Exit codes shared by every command
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command ran but failed, or found problems with the tileset
+	exitUsage   = 2 // the command line was invalid
+)
 
-	cpuProfile = flag.String("cpuprofile", "", "write cpu profile to file")
+// Grid size used when neither the flags nor the tileset's settings give one
+const (
+	defaultWidth  = 32
+	defaultHeight = 18
 )
 
+type command struct {
+	name    string
+	args    string                                       // arguments after the flags, shown in the usage line
+	summary string                                       // one line description for the command list
+	help    string                                       // longer description shown by the command's -h
+	run     func(flags *flag.FlagSet, args []string) int // flags are set up with the command's usage
+}
+
+// Commands in the order they're listed in the usage
+var commands = []command{
+	generateCommand,
+	viewCommand,
+	benchCommand,
+	statsCommand,
+	batchCommand,
+	serveCommand,
+	processCommand,
+	packCommand,
+	extractCommand,
+	migrateCommand,
+	validateCommand,
+	analyzeCommand,
+	diagnoseCommand,
+	tsxCommand,
+	importCommand,
+}
+
 func main() {
-	flag.Parse()
-	if *cpuProfile != "" {
-		f, err := os.Create(*cpuProfile)
-		if err != nil {
-			log.Fatal(err)
+	log.SetFlags(0)
+	os.Exit(run(os.Args[1:]))
+}
+
+// Runs the command named by the first argument, returning the exit code
+func run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if len(args) > 1 {
+			if cmd, ok := findCommand(args[1]); ok {
+				return cmd.run(newFlagSet(cmd), []string{"-h"})
+			}
 		}
+		printUsage(os.Stdout)
+		return exitOK
+	}
 
-		pprof.StartCPUProfile(f)
-		defer pprof.StopCPUProfile()
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		return exitUsage
 	}
 
-	if dir == nil && process == nil {
-		log.Fatalf("Require process or dir flag to be passed")
+	return cmd.run(newFlagSet(cmd), args[1:])
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
 	}
+	return command{}, false
+}
 
-	if *process != "" {
-		imageprocess.ProcessDir(*process)
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: wfc <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run wfc help <command> or wfc <command> -h for a command's flags")
+}
 
-	if *dir != "" {
-		gui.RunSimulation(*dir, *width, *height)
+// Returns the flag set for a command, printing the command's help for -h or a bad flag
+func newFlagSet(cmd command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "usage: wfc %s [flags] %s\n\n", cmd.name, cmd.args)
+		fmt.Fprintf(out, "%s\n\n", cmd.help)
+		fmt.Fprintln(out, "flags:")
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// Parses flags wherever they appear in args, so they can come before or after the arguments
+// Returns the arguments, or false and the exit code if the command shouldn't run
+func parseFlags(flags *flag.FlagSet, args []string, argCount int) ([]string, int, bool) {
+	positional := make([]string, 0, argCount)
+	for {
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, exitOK, false
+			}
+			return nil, exitUsage, false
+		}
+
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
 	}
+
+	if len(positional) != argCount {
+		fmt.Fprintf(flags.Output(), "expected %d argument(s), got %d: %s\n\n", argCount, len(positional), strings.Join(positional, " "))
+		flags.Usage()
+		return nil, exitUsage, false
+	}
+
+	return positional, exitOK, true
+}
+
+// Reports a failure and returns the failure exit code
+func fail(err error) int {
+	log.Println(err)
+	return exitFailure
+}
+
+// Returns the grid size to generate, using the tileset's settings where the flags are 0
+func gridSize(settings tileset.Settings, width, height int) (int, int) {
+	if width <= 0 {
+		width = settings.Width
+	}
+	if width <= 0 {
+		width = defaultWidth
+	}
+
+	if height <= 0 {
+		height = settings.Height
+	}
+	if height <= 0 {
+		height = defaultHeight
+	}
+
+	return width, height
+}
+
+// Starts writing a cpu profile if path is set, the returned function stops it
+func startProfile(path string) (func(), error) {
+	if path == "" {
+		return func() {}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		pprof.StopCPUProfile()
+		f.Close()
+	}, nil
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"wavefunctioncollapse/tileset"
+)
+
+func Test_parseFlags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected []string
+		code     int
+		ok       bool
+	}{
+		{"Flags before", []string{"-width", "4", "assets"}, []string{"assets"}, exitOK, true},
+		{"Flags after", []string{"assets", "-width=4"}, []string{"assets"}, exitOK, true},
+		{"Missing argument", []string{"-width=4"}, nil, exitUsage, false},
+		{"Extra argument", []string{"assets", "more"}, nil, exitUsage, false},
+		{"Unknown flag", []string{"-depth=4", "assets"}, nil, exitUsage, false},
+		{"Help", []string{"-h"}, nil, exitOK, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			flags := newFlagSet(command{name: "test"})
+			flags.SetOutput(io.Discard)
+			width := flags.Int("width", 0, "")
+
+			positional, code, ok := parseFlags(flags, tc.args, 1)
+			if ok != tc.ok || code != tc.code || (ok && !reflect.DeepEqual(positional, tc.expected)) {
+				t.Errorf("Failed, expected %v %v %v, got %v %v %v", tc.expected, tc.code, tc.ok, positional, code, ok)
+			}
+
+			if ok && *width != 4 {
+				t.Errorf("Failed, expected width %v, got %v", 4, *width)
+			}
+		})
+	}
+}
+
+func Test_run_unknownCommand(t *testing.T) {
+	if code := run([]string{"nope"}); code != exitUsage {
+		t.Errorf("Failed, expected exit code %v, got %v", exitUsage, code)
+	}
+}
+
+// Writes a tileset of tiles that fit anywhere using the named images, only blank.png is copied from assets
+func writeTileset(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	tiles := make([]string, len(names))
+	for idx, name := range names {
+		tiles[idx] = fmt.Sprintf(`{"name": %q, "connections": {"left": "AAA", "up": "AAA", "right": "AAA", "down": "AAA"}}`, name)
+	}
+	config := fmt.Sprintf(`{"version": 2, "tiles": [%s]}`, strings.Join(tiles, ", "))
+	if err := os.WriteFile(path.Join(dir, "config.json"), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	img, err := os.ReadFile("assets/blank.png")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	if err := os.WriteFile(path.Join(dir, "blank.png"), img, 0644); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	return dir
+}
+
+func Test_run_singleTile(t *testing.T) {
+	// A tileset with one tile loads, but can't generate
+	dir := writeTileset(t, "blank.png")
+
+	out := t.TempDir()
+	for _, args := range [][]string{
+		{"generate", "-out=" + path.Join(out, "map.json"), dir},
+		{"batch", "-count=2", "-out=" + out, dir},
+		{"bench", "-runs=1", dir},
+		{"stats", "-runs=1", dir},
+		{"diagnose", "-report=" + path.Join(out, "report.txt"), dir},
+	} {
+		if code := run(args); code != exitFailure {
+			t.Errorf("Failed, expected %v to exit with %v, got %v", args, exitFailure, code)
+		}
+	}
+}
+
+func Test_resultWriter_prepare(t *testing.T) {
+	ts, err := tileset.Load(writeTileset(t, "blank.png", "missing.png"))
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	writer := newResultWriter(ts, 0)
+	if err := writer.prepare("map.json"); err != nil {
+		t.Errorf("Failed, expected a map file not to need the images, got %v", err)
+	}
+	for _, out := range []string{"map.png", "map.ldtk"} {
+		if err := writer.prepare(out); err == nil {
+			t.Errorf("Failed, expected %s to fail on the missing image before generating", out)
+		}
+	}
+}
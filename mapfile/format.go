@@ -0,0 +1,228 @@
+package mapfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"wavefunctioncollapse/wfc"
+)
+
+// Marks the start of a binary map
+var binaryMagic = [4]byte{'W', 'F', 'C', 'M'}
+
+// Stands in for wfc.Undecided in a binary map, as IDs are unsigned
+const binaryUndecided = math.MaxUint16
+
+// Most tiles a binary map can have, so a corrupt header can't ask for an enormous grid
+const maxBinaryTiles = 1 << 24
+
+// Layout of a JSON map, rows are indexed [y][x] so the file reads like the map
+type jsonMap struct {
+	Version int        `json:"version"`
+	Width   int        `json:"width"`
+	Height  int        `json:"height"`
+	Seed    int64      `json:"seed,omitempty"`
+	Tileset string     `json:"tileset,omitempty"`
+	Tiles   []TileInfo `json:"tiles,omitempty"`
+	Rows    [][]int    `json:"rows"`
+}
+
+// Header of a binary map, followed by a uint16 ID for every position by row
+type binaryHeader struct {
+	Magic   [4]byte
+	Version uint16
+	Width   uint32
+	Height  uint32
+	Seed    int64
+}
+
+// Writes a map in the given format
+func Encode(w io.Writer, m *Map, format Format) error {
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jsonMap{
+			Version: CurrentVersion,
+			Width:   m.Width,
+			Height:  m.Height,
+			Seed:    m.Seed,
+			Tileset: m.Tileset,
+			Tiles:   m.Tiles,
+			Rows:    m.rows(),
+		})
+
+	case CSV:
+		writer := csv.NewWriter(w)
+		for _, row := range m.rows() {
+			record := make([]string, len(row))
+			for x, id := range row {
+				record[x] = strconv.Itoa(id)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case Binary:
+		buf := bufio.NewWriter(w)
+		header := binaryHeader{binaryMagic, CurrentVersion, uint32(m.Width), uint32(m.Height), m.Seed}
+		if err := binary.Write(buf, binary.LittleEndian, header); err != nil {
+			return err
+		}
+
+		ids := make([]uint16, 0, m.Width*m.Height)
+		for _, row := range m.rows() {
+			for x, id := range row {
+				if id == wfc.Undecided {
+					ids = append(ids, binaryUndecided)
+					continue
+				}
+				if id < 0 || id >= binaryUndecided {
+					return fmt.Errorf("tile %d at (%d, %d) is too large for a binary map", id, x, len(ids)/m.Width)
+				}
+				ids = append(ids, uint16(id))
+			}
+		}
+		if err := binary.Write(buf, binary.LittleEndian, ids); err != nil {
+			return err
+		}
+		return buf.Flush()
+	}
+
+	return fmt.Errorf("unknown map format %d", format)
+}
+
+// Reads a map in the given format
+func Decode(r io.Reader, format Format) (*Map, error) {
+	switch format {
+	case JSON:
+		var data jsonMap
+		if err := json.NewDecoder(r).Decode(&data); err != nil {
+			return nil, err
+		}
+		if data.Version != CurrentVersion {
+			return nil, fmt.Errorf("unsupported map version %d, expected %d", data.Version, CurrentVersion)
+		}
+
+		m, err := fromRows(data.Rows)
+		if err != nil {
+			return nil, err
+		}
+		if m.Width != data.Width || m.Height != data.Height {
+			return nil, fmt.Errorf("map is %dx%d but has %dx%d rows", data.Width, data.Height, m.Width, m.Height)
+		}
+		m.Seed, m.Tileset, m.Tiles = data.Seed, data.Tileset, data.Tiles
+		return m, nil
+
+	case CSV:
+		records, err := csv.NewReader(r).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+
+		rows := make([][]int, len(records))
+		for y, record := range records {
+			rows[y] = make([]int, len(record))
+			for x, field := range record {
+				if rows[y][x], err = strconv.Atoi(field); err != nil {
+					return nil, fmt.Errorf("invalid tile %q at (%d, %d)", field, x, y)
+				}
+			}
+		}
+		return fromRows(rows)
+
+	case Binary:
+		var header binaryHeader
+		if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+			return nil, fmt.Errorf("invalid binary map header: %v", err)
+		}
+		if header.Magic != binaryMagic {
+			return nil, errors.New("not a binary map, missing the WFCM header")
+		}
+		if header.Version != CurrentVersion {
+			return nil, fmt.Errorf("unsupported map version %d, expected %d", header.Version, CurrentVersion)
+		}
+
+		if header.Width == 0 || header.Height == 0 {
+			return nil, errors.New("map has no tiles")
+		}
+		if uint64(header.Width)*uint64(header.Height) > maxBinaryTiles {
+			return nil, fmt.Errorf("%dx%d map has more than the %d tiles allowed", header.Width, header.Height, maxBinaryTiles)
+		}
+
+		// Only read as much as the header asks for, so the allocation is bounded by the data actually there
+		width, height := int(header.Width), int(header.Height)
+		data, err := io.ReadAll(io.LimitReader(r, int64(2*width*height)))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != 2*width*height {
+			return nil, fmt.Errorf("expected %d tiles for a %dx%d map, got %d", width*height, width, height, len(data)/2)
+		}
+
+		m := &Map{Width: width, Height: height, Seed: header.Seed, Grid: newGrid(width, height)}
+		for idx := 0; idx < width*height; idx++ {
+			id := binary.LittleEndian.Uint16(data[2*idx:])
+			if id == binaryUndecided {
+				m.Grid[idx%width][idx/width] = wfc.Undecided
+			} else {
+				m.Grid[idx%width][idx/width] = int(id)
+			}
+		}
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("unknown map format %d", format)
+}
+
+// Returns the grid by row, as the formats store it
+func (m *Map) rows() [][]int {
+	rows := make([][]int, m.Height)
+	for y := range rows {
+		rows[y] = make([]int, m.Width)
+		for x := range rows[y] {
+			rows[y][x] = m.Grid[x][y]
+		}
+	}
+	return rows
+}
+
+// Returns a map from a grid stored by row
+func fromRows(rows [][]int) (*Map, error) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return nil, errors.New("map has no tiles")
+	}
+
+	width, height := len(rows[0]), len(rows)
+	grid := newGrid(width, height)
+	for y, row := range rows {
+		if len(row) != width {
+			return nil, fmt.Errorf("row %d has %d tiles, expected %d", y, len(row), width)
+		}
+		for x, id := range row {
+			if id < wfc.Undecided {
+				return nil, fmt.Errorf("invalid tile %d at (%d, %d)", id, x, y)
+			}
+			grid[x][y] = id
+		}
+	}
+
+	return &Map{Width: width, Height: height, Grid: grid}, nil
+}
+
+func newGrid(width, height int) [][]int {
+	grid := make([][]int, width)
+	for x := range grid {
+		grid[x] = make([]int, height)
+	}
+	return grid
+}
@@ -0,0 +1,167 @@
+package mapfile
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"strings"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Version of the JSON and binary formats written by this package
+const CurrentVersion = 1
+
+// Map is a generated grid of tile IDs with what's needed to make sense of it outside of the generator
+type Map struct {
+	Width, Height int
+	Seed          int64      // seed the map was generated with, 0 if unknown
+	Tileset       string     // directory of the tileset the IDs index into
+	Tiles         []TileInfo // tile for each ID, empty when the format doesn't store them
+	Grid          [][]int    // tile ID at [x][y], wfc.Undecided for positions that were never collapsed
+}
+
+// TileInfo describes the tile behind an ID, so a map can be used without loading the tileset
+type TileInfo struct {
+	Id          int               `json:"id"`
+	Name        string            `json:"name"`
+	Transform   tileset.Transform `json:"transform,omitempty"`
+	DisplayName string            `json:"displayName,omitempty"`
+}
+
+// Returns a map of a result generated from the tileset
+func New(ts *tileset.Tileset, result [][]int, seed int64) *Map {
+	tiles := make([]TileInfo, len(ts.Tiles))
+	for id, tile := range ts.Tiles {
+		tiles[id] = TileInfo{Id: id, Name: tile.Name, Transform: tile.Transform, DisplayName: tile.DisplayName}
+	}
+
+	height := 0
+	if len(result) > 0 {
+		height = len(result[0])
+	}
+
+	return &Map{
+		Width:   len(result),
+		Height:  height,
+		Seed:    seed,
+		Tileset: ts.Dir(),
+		Tiles:   tiles,
+		Grid:    result,
+	}
+}
+
+// Format is a file format a map can be written in
+type Format int
+
+const (
+	JSON   Format = iota // IDs by row with the tiles and seed
+	CSV                  // IDs by row only
+	Binary               // compact header and 16 bit IDs by row
+)
+
+// Returns the format for a file from its extension
+func FormatOf(name string) (Format, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return JSON, nil
+	case ".csv":
+		return CSV, nil
+	case ".bin", ".wfcm":
+		return Binary, nil
+	}
+	return 0, fmt.Errorf("unknown map format for %s, expected .json, .csv or .bin", name)
+}
+
+// Reports whether a file name has the extension of a map format
+func IsMapFile(name string) bool {
+	_, err := FormatOf(name)
+	return err == nil
+}
+
+// Reads a map, detecting the format from the extension
+func Read(filePath string) (*Map, error) {
+	format, err := FormatOf(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open map %s with error %v", filePath, err)
+	}
+	defer f.Close()
+
+	m, err := Decode(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map %s with error %v", filePath, err)
+	}
+	return m, nil
+}
+
+// Writes a map, picking the format from the extension
+func Write(filePath string, m *Map) error {
+	format, err := FormatOf(filePath)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create map %s with error %v", filePath, err)
+	}
+
+	if err := Encode(f, m, format); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write map %s with error %v", filePath, err)
+	}
+	return f.Close()
+}
+
+// Checks the map can be used with a tileset, every ID must be in the tileset and any stored tiles must match it
+func (m *Map) Check(ts *tileset.Tileset) error {
+	for x := range m.Grid {
+		for y, id := range m.Grid[x] {
+			if id != wfc.Undecided && (id < 0 || id >= len(ts.Tiles)) {
+				return fmt.Errorf("tile %d at (%d, %d) isn't in the tileset, which has %d tiles", id, x, y, len(ts.Tiles))
+			}
+		}
+	}
+
+	for _, info := range m.Tiles {
+		if info.Id < 0 || info.Id >= len(ts.Tiles) {
+			return fmt.Errorf("map tile %d %s isn't in the tileset, which has %d tiles", info.Id, info.Name, len(ts.Tiles))
+		}
+
+		tile := ts.Tiles[info.Id]
+		if tile.Name != info.Name || tile.Transform != info.Transform {
+			return fmt.Errorf("map tile %d is %s but the tileset has %s, the map is from another tileset or an older version of it",
+				info.Id, describe(info.Name, info.Transform), describe(tile.Name, tile.Transform))
+		}
+	}
+
+	return nil
+}
+
+func describe(name string, transform tileset.Transform) string {
+	if transform == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, transform)
+}
+
+// Returns constraints pinning every decided position outside of clear to its tile
+// Generating with them keeps the rest of the map and fills in clear and any undecided positions
+func (m *Map) Constraints(clear image.Rectangle) []wfc.Constraint {
+	constraints := make([]wfc.Constraint, 0, m.Width*m.Height)
+	for x := range m.Grid {
+		for y, id := range m.Grid[x] {
+			if id == wfc.Undecided || image.Pt(x, y).In(clear) {
+				continue
+			}
+			constraints = append(constraints, wfc.Constraint{X: x, Y: y, Tiles: []int{id}})
+		}
+	}
+	return constraints
+}
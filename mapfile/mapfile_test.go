@@ -0,0 +1,143 @@
+package mapfile
+
+import (
+	"bytes"
+	"image"
+	"path"
+	"reflect"
+	"testing"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+func testMap(t *testing.T) (*tileset.Tileset, *Map) {
+	ts, err := tileset.Load("../assets/circuit")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	grid := [][]int{{0, 1}, {wfc.Undecided, 2}, {3, 0}}
+	return ts, New(ts, grid, 42)
+}
+
+func Test_formats_roundTrip(t *testing.T) {
+	_, m := testMap(t)
+
+	for _, format := range []Format{JSON, CSV, Binary} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, m, format); err != nil {
+			t.Fatalf("Failed, %v expected no error, got %v", format, err)
+		}
+
+		res, err := Decode(&buf, format)
+		if err != nil {
+			t.Fatalf("Failed, %v expected no error, got %v", format, err)
+		}
+
+		if !reflect.DeepEqual(m.Grid, res.Grid) || res.Width != 3 || res.Height != 2 {
+			t.Errorf("Failed, %v expected grid %v, got %v", format, m.Grid, res.Grid)
+		}
+		if format != CSV && res.Seed != m.Seed {
+			t.Errorf("Failed, %v expected seed %v, got %v", format, m.Seed, res.Seed)
+		}
+		if format == JSON && (!reflect.DeepEqual(m.Tiles, res.Tiles) || res.Tileset != m.Tileset) {
+			t.Errorf("Failed, expected tiles %v from %v, got %v from %v", m.Tiles, m.Tileset, res.Tiles, res.Tileset)
+		}
+	}
+}
+
+func Test_Encode_rows(t *testing.T) {
+	_, m := testMap(t)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, CSV); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	expected := "0,-1,3\n1,2,0\n"
+	if buf.String() != expected {
+		t.Errorf("Failed, expected %q, got %q", expected, buf.String())
+	}
+}
+
+func Test_Decode_invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format Format
+		data   string
+	}{
+		{"Uneven rows", CSV, "0,1\n2\n"},
+		{"Not an ID", CSV, "0,a\n"},
+		{"Wrong size", JSON, `{"version": 1, "width": 3, "height": 1, "rows": [[0, 1]]}`},
+		{"Unknown version", JSON, `{"version": 9, "width": 1, "height": 1, "rows": [[0]]}`},
+		{"Missing magic", Binary, "WFCX\x01\x00\x01\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{"Truncated", Binary, "WFCM\x01\x00\x02\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{"No columns", Binary, "WFCM\x01\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{"No rows", Binary, "WFCM\x01\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{"Enormous", Binary, "WFCM\x01\x00\xff\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{"Larger than the data", Binary, "WFCM\x01\x00\x00\x10\x00\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewBufferString(tc.data), tc.format); err == nil {
+				t.Errorf("Failed, expected an error")
+			}
+		})
+	}
+}
+
+func Test_ReadWrite(t *testing.T) {
+	_, m := testMap(t)
+	dir := t.TempDir()
+
+	for _, name := range []string{"map.json", "map.csv", "map.bin"} {
+		filePath := path.Join(dir, name)
+		if err := Write(filePath, m); err != nil {
+			t.Fatalf("Failed, %s expected no error, got %v", name, err)
+		}
+
+		res, err := Read(filePath)
+		if err != nil || !reflect.DeepEqual(m.Grid, res.Grid) {
+			t.Errorf("Failed, %s expected grid %v, got %v with err %v", name, m.Grid, res, err)
+		}
+	}
+
+	if err := Write(path.Join(dir, "map.png"), m); err == nil {
+		t.Errorf("Failed, expected an error for an unknown format")
+	}
+}
+
+func Test_Map_Check(t *testing.T) {
+	ts, m := testMap(t)
+	if err := m.Check(ts); err != nil {
+		t.Errorf("Failed, expected no error, got %v", err)
+	}
+
+	m.Grid[0][0] = len(ts.Tiles)
+	if err := m.Check(ts); err == nil {
+		t.Errorf("Failed, expected an error for a tile outside the tileset")
+	}
+
+	m.Grid[0][0] = 0
+	m.Tiles[1].Name = "renamed.png"
+	if err := m.Check(ts); err == nil {
+		t.Errorf("Failed, expected an error for a tile that doesn't match the tileset")
+	}
+}
+
+func Test_Map_Constraints(t *testing.T) {
+	_, m := testMap(t)
+
+	constraints := m.Constraints(image.Rect(2, 0, 3, 1))
+	expected := []wfc.Constraint{
+		{X: 0, Y: 0, Tiles: []int{0}},
+		{X: 0, Y: 1, Tiles: []int{1}},
+		{X: 1, Y: 1, Tiles: []int{2}},
+		{X: 2, Y: 1, Tiles: []int{0}},
+	}
+
+	if !reflect.DeepEqual(expected, constraints) {
+		t.Errorf("Failed, expected %v, got %v", expected, constraints)
+	}
+}
@@ -0,0 +1,87 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/disintegration/imaging"
+)
+
+// Returns the image for each tile ID in the tileset, with the tile's transform applied
+func LoadImages(ts *tileset.Tileset) (map[int]image.Image, error) {
+	loader := ts.Loader()
+	images := make(map[int]image.Image, len(ts.Tiles))
+	for id, tile := range ts.Tiles {
+		// Variants only exist in memory, so apply the tile's transform to its base image
+		img, err := loader.Image(tile)
+		if err != nil {
+			return nil, err
+		}
+		images[id] = img
+	}
+
+	return images, nil
+}
+
+// Composes the tile images for a result into a single image, each tile drawn tileSize pixels square
+// A tileSize of 0 uses the width of the image with the lowest tile ID, undecided positions are left transparent
+func Render(result [][]int, images map[int]image.Image, tileSize int) (*image.NRGBA, error) {
+	if tileSize <= 0 {
+		// Maps have no order, so pick the lowest ID rather than whichever image comes first
+		first := -1
+		for id := range images {
+			if first == -1 || id < first {
+				first = id
+			}
+		}
+		if first != -1 {
+			tileSize = images[first].Bounds().Dx()
+		}
+	}
+
+	if tileSize <= 0 || len(result) == 0 {
+		return nil, fmt.Errorf("nothing to render, tile size %d for %d columns", tileSize, len(result))
+	}
+
+	// Scale every tile once up front, a tileset is far smaller than the grid
+	scaled := make(map[int]image.Image, len(images))
+	for id, img := range images {
+		scaled[id] = imaging.Resize(img, tileSize, tileSize, imaging.NearestNeighbor)
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, len(result)*tileSize, len(result[0])*tileSize))
+	for row := range result {
+		for col, id := range result[row] {
+			if id == wfc.Undecided {
+				continue
+			}
+
+			img, ok := scaled[id]
+			if !ok {
+				return nil, fmt.Errorf("no image for tile %d at position (%d, %d)", id, row, col)
+			}
+
+			dst := image.Rect(row*tileSize, col*tileSize, (row+1)*tileSize, (col+1)*tileSize)
+			draw.Draw(out, dst, img, img.Bounds().Min, draw.Over)
+		}
+	}
+
+	return out, nil
+}
+
+// Renders a result and writes it to a PNG file
+func SavePNG(outPath string, result [][]int, images map[int]image.Image, tileSize int) error {
+	img, err := Render(result, images, tileSize)
+	if err != nil {
+		return err
+	}
+
+	if err := imaging.Save(img, outPath); err != nil {
+		return fmt.Errorf("failed to save %s with error %v", outPath, err)
+	}
+
+	return nil
+}
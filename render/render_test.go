@@ -0,0 +1,77 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"wavefunctioncollapse/wfc"
+)
+
+func filled(size int, clr color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			img.Set(x, y, clr)
+		}
+	}
+	return img
+}
+
+func Test_Render(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	blue := color.NRGBA{0, 0, 0xff, 0xff}
+	images := map[int]image.Image{0: filled(2, red), 1: filled(2, blue)}
+	result := [][]int{{0, 1}, {wfc.Undecided, 0}}
+
+	img, err := Render(result, images, 0)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("Failed, expected a 4x4 image, got %v", img.Bounds())
+	}
+
+	// Rows run across the image and columns down it, each position 2 pixels square
+	testCases := []struct {
+		x, y     int
+		expected color.NRGBA
+	}{
+		{0, 0, red},
+		{1, 1, red},
+		{0, 2, blue},
+		{1, 3, blue},
+		{2, 0, color.NRGBA{}},
+		{3, 1, color.NRGBA{}},
+		{2, 2, red},
+		{3, 3, red},
+	}
+	for _, tc := range testCases {
+		if got := img.NRGBAAt(tc.x, tc.y); got != tc.expected {
+			t.Errorf("Failed, pixel (%d, %d) expected %v, got %v", tc.x, tc.y, tc.expected, got)
+		}
+	}
+}
+
+func Test_Render_tileSize(t *testing.T) {
+	images := map[int]image.Image{}
+	for id := 0; id < 8; id++ {
+		images[id] = filled(id+2, color.White)
+	}
+
+	// Repeated, as a size taken from whichever image a map iteration reaches first would vary
+	for run := 0; run < 20; run++ {
+		img, err := Render([][]int{{0, 1}, {2, 3}}, images, 0)
+		if err != nil || img.Bounds().Dx() != 4 {
+			t.Fatalf("Failed, expected tiles the size of tile 0, got %v with err %v", img, err)
+		}
+	}
+
+	img, err := Render([][]int{{0}}, images, 5)
+	if err != nil || img.Bounds() != image.Rect(0, 0, 5, 5) {
+		t.Errorf("Failed, expected a 5x5 image, got %v with err %v", img, err)
+	}
+
+	if _, err := Render([][]int{{9}}, images, 0); err == nil {
+		t.Errorf("Failed, expected an error for a tile without an image")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"time"
+	"wavefunctioncollapse/server"
+)
+
+var serveCommand = command{
+	name:    "serve",
+	args:    "<tilesets dir>",
+	summary: "generate maps over HTTP",
+	help: "Serves an HTTP API generating maps with the tilesets in the directory, named by their path relative to it.\n" +
+		"POST /generate takes a JSON request and answers with the map as JSON or a PNG, GET /tilesets lists the tilesets\n" +
+		"and POST /tilesets uploads a zip of one to -uploads. Every generation is limited by -timeout, -max-cells and\n" +
+		"-max-attempts, and only -concurrency run at once.",
+	run: runServe,
+}
+
+func runServe(flags *flag.FlagSet, args []string) int {
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	uploads := flags.String("uploads", "", "directory to unpack uploaded tilesets to, uploads are refused if it isn't set")
+	config := server.Config{}
+	flags.DurationVar(&config.Timeout, "timeout", server.DefaultTimeout, "longest a request can wait for and run a generation")
+	flags.IntVar(&config.MaxCells, "max-cells", server.DefaultMaxCells, "largest width times height a request can generate")
+	flags.IntVar(&config.MaxPixels, "max-pixels", server.DefaultMaxPixels, "largest PNG a request can render, in pixels")
+	flags.IntVar(&config.MaxAttempts, "max-attempts", server.DefaultMaxAttempts, "most attempts a request can run at once")
+	flags.Int64Var(&config.MaxUploadBytes, "max-upload", server.DefaultMaxUploadBytes, "largest tileset archive that can be uploaded, in bytes")
+	flags.IntVar(&config.MaxImagePixels, "max-image-pixels", server.DefaultMaxImagePixels, "largest tile or atlas image a tileset can hold, in pixels")
+	flags.IntVar(&config.MaxUploads, "max-uploads", server.DefaultMaxUploads, "most uploaded tilesets to keep, the least recently uploaded are removed to make room")
+	flags.IntVar(&config.MaxConcurrent, "concurrency", runtime.NumCPU(), "generations to run at once, later requests wait for a free slot")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	config.TilesetDir, config.UploadDir = positional[0], *uploads
+	handler, err := server.New(config)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Generations are bounded by -timeout, the rest only stop slow clients holding connections open
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      config.Timeout + time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving tilesets in %s on http://%s", config.TilesetDir, *addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fail(fmt.Errorf("failed to serve with error %v", err))
+	}
+	return exitOK
+}
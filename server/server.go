@@ -0,0 +1,470 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wavefunctioncollapse/mapfile"
+	"wavefunctioncollapse/render"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Limits used for any Config field left at 0
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultMaxCells       = 128 * 128
+	DefaultMaxPixels      = 4096 * 4096
+	DefaultMaxAttempts    = 4
+	DefaultMaxUploadBytes = 32 << 20
+	DefaultMaxImagePixels = 4096 * 4096
+	DefaultMaxUploads     = 256
+	DefaultMaxConcurrent  = 4
+)
+
+// Largest generate request body, constraints for every position of the largest grid fit comfortably
+const maxRequestBytes = 4 << 20
+
+// Config sets where tilesets come from and the limits on every request
+type Config struct {
+	TilesetDir     string        // tilesets requests can name, by their path relative to it
+	UploadDir      string        // where uploaded tilesets are unpacked, uploads are refused if it's empty
+	Timeout        time.Duration // longest a generation can run, including waiting for a free slot
+	MaxCells       int           // largest width times height a request can generate
+	MaxPixels      int           // largest PNG, in pixels, a request can render
+	MaxAttempts    int           // most attempts a request can run at once
+	MaxUploadBytes int64         // largest uploaded archive, and the most its files can unpack to
+	MaxImagePixels int           // largest tile or atlas image, in pixels, a tileset can hold
+	MaxUploads     int           // most uploaded tilesets kept, the least recently uploaded are removed to make room
+	MaxConcurrent  int           // generations run at once across all requests, the rest wait for a slot
+}
+
+// Server answers generation requests over HTTP
+type Server struct {
+	config Config
+	slots  chan struct{} // holds a value for every generation running
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	tilesets map[string]*loadedTileset // cached by directory
+}
+
+// A tileset loaded for generating, its images are only loaded the first time a PNG is rendered
+type loadedTileset struct {
+	ts        *tileset.Tileset
+	tiles     []wfc.Tile
+	loadOnce  sync.Once
+	images    map[int]image.Image
+	imagesErr error
+}
+
+// GenerateRequest is the body of POST /generate
+type GenerateRequest struct {
+	Tileset     string       `json:"tileset"`            // name of a tileset in the tileset directory, or returned by an upload
+	Width       int          `json:"width,omitempty"`    // defaults to the tileset's width
+	Height      int          `json:"height,omitempty"`   // defaults to the tileset's height
+	Seed        int64        `json:"seed,omitempty"`     // 0 for a random seed
+	Attempts    int          `json:"attempts,omitempty"` // attempts to run at once, the first to succeed is returned
+	Constraints []Constraint `json:"constraints,omitempty"`
+	Format      string       `json:"format,omitempty"`   // json or png, defaults to json
+	TileSize    int          `json:"tileSize,omitempty"` // pixel size of each tile in a PNG, defaults to the tileset's tile size or the tile image size
+}
+
+// Constraint limits the tiles allowed at a position, the same as wfc.Constraint
+type Constraint struct {
+	X     int   `json:"x"`
+	Y     int   `json:"y"`
+	Tiles []int `json:"tiles"`
+}
+
+// Returned with every error status
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// An error with the status to answer it with
+type statusError struct {
+	status int
+	err    error
+}
+
+func (err *statusError) Error() string {
+	return err.err.Error()
+}
+
+func errorf(status int, format string, args ...interface{}) error {
+	return &statusError{status, fmt.Errorf(format, args...)}
+}
+
+// Returns a server for the config, filling in defaults for its limits
+func New(config Config) (*Server, error) {
+	if config.TilesetDir == "" && config.UploadDir == "" {
+		return nil, fmt.Errorf("no tileset directory or upload directory, there would be no tilesets to generate with")
+	}
+	if config.UploadDir != "" {
+		if err := os.MkdirAll(config.UploadDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create upload directory %s with error %v", config.UploadDir, err)
+		}
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.MaxCells <= 0 {
+		config.MaxCells = DefaultMaxCells
+	}
+	if config.MaxPixels <= 0 {
+		config.MaxPixels = DefaultMaxPixels
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.MaxUploadBytes <= 0 {
+		config.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	if config.MaxConcurrent <= 0 {
+		config.MaxConcurrent = DefaultMaxConcurrent
+	}
+	if config.MaxImagePixels <= 0 {
+		config.MaxImagePixels = DefaultMaxImagePixels
+	}
+	if config.MaxUploads <= 0 {
+		config.MaxUploads = DefaultMaxUploads
+	}
+
+	s := &Server{
+		config:   config,
+		slots:    make(chan struct{}, config.MaxConcurrent),
+		mux:      http.NewServeMux(),
+		tilesets: make(map[string]*loadedTileset),
+	}
+	s.mux.HandleFunc("/generate", s.handleGenerate)
+	s.mux.HandleFunc("/tilesets", s.handleTilesets)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Generates a map from a GenerateRequest, answering with the map as JSON, or a PNG with the seed in the X-Wfc-Seed header
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, errorf(http.StatusMethodNotAllowed, "%s isn't allowed, POST a generate request", r.Method))
+		return
+	}
+
+	var req GenerateRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, errorf(http.StatusRequestEntityTooLarge, "request is larger than %d bytes", tooLarge.Limit))
+			return
+		}
+		writeError(w, errorf(http.StatusBadRequest, "invalid request: %v", err))
+		return
+	}
+
+	start := time.Now()
+	solver, res, loaded, err := s.generate(r.Context(), &req)
+	if solver != nil {
+		log.Printf("generate %s %dx%d: %v in %v", req.Tileset, req.Width, req.Height, outcome(solver, err), time.Since(start).Round(time.Millisecond))
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if req.Format == "png" {
+		writePNG(w, loaded, res, req.TileSize, solver.Seed())
+		return
+	}
+
+	m := mapfile.New(loaded.ts, res, solver.Seed())
+	// The server's directories are no business of the client, the name they asked for is
+	m.Tileset = req.Tileset
+	w.Header().Set("Content-Type", "application/json")
+	if err := mapfile.Encode(w, m, mapfile.JSON); err != nil {
+		log.Printf("failed to write response with error %v", err)
+	}
+}
+
+// Checks a request against the limits and runs it once a slot is free
+// Fills in the request's width and height from the tileset if they weren't given
+func (s *Server) generate(ctx context.Context, req *GenerateRequest) (*wfc.Solver, [][]int, *loadedTileset, error) {
+	switch req.Format {
+	case "":
+		req.Format = "json"
+	case "json", "png":
+	default:
+		return nil, nil, nil, errorf(http.StatusBadRequest, "unknown format %q, expected json or png", req.Format)
+	}
+
+	loaded, err := s.tileset(req.Tileset)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(loaded.tiles) < 2 {
+		return nil, nil, nil, errorf(http.StatusUnprocessableEntity, "tileset %s needs at least 2 tiles to generate", req.Tileset)
+	}
+
+	if req.Width == 0 {
+		req.Width = loaded.ts.Settings.Width
+	}
+	if req.Height == 0 {
+		req.Height = loaded.ts.Settings.Height
+	}
+	if req.Width <= 0 || req.Height <= 0 {
+		return nil, nil, nil, errorf(http.StatusBadRequest, "width and height must be positive, got %dx%d", req.Width, req.Height)
+	}
+	if req.Width*req.Height > s.config.MaxCells || req.Width > s.config.MaxCells || req.Height > s.config.MaxCells {
+		return nil, nil, nil, errorf(http.StatusRequestEntityTooLarge, "%dx%d is more than the %d positions allowed", req.Width, req.Height, s.config.MaxCells)
+	}
+	if req.Attempts > s.config.MaxAttempts {
+		return nil, nil, nil, errorf(http.StatusBadRequest, "%d attempts is more than the %d allowed", req.Attempts, s.config.MaxAttempts)
+	}
+	if req.Format == "png" {
+		// Refuse images that are too large before spending any time generating
+		if err := s.checkImageSize(req, loaded); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	constraints := make([]wfc.Constraint, len(req.Constraints))
+	for idx, constraint := range req.Constraints {
+		if constraint.X < 0 || constraint.X >= req.Width || constraint.Y < 0 || constraint.Y >= req.Height {
+			return nil, nil, nil, errorf(http.StatusBadRequest, "constraint at (%d, %d) is outside the %dx%d grid", constraint.X, constraint.Y, req.Width, req.Height)
+		}
+		for _, id := range constraint.Tiles {
+			if id < 0 || id >= len(loaded.tiles) {
+				return nil, nil, nil, errorf(http.StatusBadRequest, "constraint at (%d, %d) allows tile %d, which isn't in the tileset", constraint.X, constraint.Y, id)
+			}
+		}
+		constraints[idx] = wfc.Constraint{X: constraint.X, Y: constraint.Y, Tiles: constraint.Tiles}
+	}
+
+	// Waiting for a slot counts towards the timeout, so a queue of slow requests can't hold clients forever
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return nil, nil, nil, errorf(http.StatusServiceUnavailable, "server busy, no free slot to generate in within %v", s.config.Timeout)
+	}
+
+	opts := wfc.Options{Seed: req.Seed, Attempts: req.Attempts, Constraints: constraints}
+	solver, res, err := wfc.Solve(ctx, loaded.tiles, req.Width, req.Height, opts)
+	if solver == nil {
+		return nil, nil, nil, errorf(http.StatusUnprocessableEntity, "%v", err)
+	}
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusServiceUnavailable
+		}
+		return solver, nil, nil, &statusError{status, fmt.Errorf("%v, seed %d", err, solver.Seed())}
+	}
+	return solver, res, loaded, nil
+}
+
+// Loads the tileset's images and checks the PNG a request asks for is within the limit, filling in its tile size
+func (s *Server) checkImageSize(req *GenerateRequest, loaded *loadedTileset) error {
+	loaded.loadOnce.Do(func() {
+		// Uploads are checked when they arrive, this also covers the server's own tilesets
+		if loaded.imagesErr = checkImages(loaded.ts, s.config.MaxImagePixels); loaded.imagesErr == nil {
+			loaded.images, loaded.imagesErr = render.LoadImages(loaded.ts)
+		}
+	})
+	var checkErr *statusError
+	if errors.As(loaded.imagesErr, &checkErr) {
+		return checkErr
+	}
+	if loaded.imagesErr != nil {
+		return errorf(http.StatusUnprocessableEntity, "failed to load the tileset's images: %s", stripDir(loaded.imagesErr, loaded.ts.Dir(), req.Tileset))
+	}
+
+	if req.TileSize <= 0 {
+		req.TileSize = loaded.ts.Settings.TileSize
+	}
+	if req.TileSize <= 0 {
+		req.TileSize = loaded.images[0].Bounds().Dx()
+	}
+	// A tile larger than the whole limit is refused before multiplying, so the sizes can't overflow
+	if req.TileSize <= 0 || req.TileSize > s.config.MaxPixels {
+		return errorf(http.StatusBadRequest, "tileSize %d is outside 1 to %d", req.TileSize, s.config.MaxPixels)
+	}
+	if req.TileSize > s.config.MaxPixels/(req.Width*req.Height)/req.TileSize {
+		return errorf(http.StatusRequestEntityTooLarge, "a %dx%d image of %d pixel tiles is more than the %d pixels allowed, ask for a smaller tileSize",
+			req.Width, req.Height, req.TileSize, s.config.MaxPixels)
+	}
+	return nil
+}
+
+// Renders a result with the tileset's images, which checkImageSize loaded
+func writePNG(w http.ResponseWriter, loaded *loadedTileset, res [][]int, tileSize int, seed int64) {
+	img, err := render.Render(res, loaded.images, tileSize)
+	if err != nil {
+		writeError(w, errorf(http.StatusInternalServerError, "%v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Wfc-Seed", strconv.FormatInt(seed, 10))
+	if err := png.Encode(w, img); err != nil {
+		log.Printf("failed to write response with error %v", err)
+	}
+}
+
+// Lists the tilesets with GET, or uploads one with POST
+func (s *Server) handleTilesets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		names, err := s.tilesetNames()
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]string{"tilesets": names})
+	case http.MethodPost:
+		name, err := s.upload(w, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		log.Printf("uploaded tileset %s", name)
+		writeJSON(w, http.StatusCreated, map[string]string{"tileset": name})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, errorf(http.StatusMethodNotAllowed, "%s isn't allowed, GET the tilesets or POST a zip of one", r.Method))
+	}
+}
+
+// Returns the tileset with the given name, loading it the first time it's asked for
+// Uploaded tilesets are named by the hash of their archive, anything else is a path in the tileset directory
+func (s *Server) tileset(name string) (*loadedTileset, error) {
+	dir, err := s.tilesetPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if loaded, ok := s.tilesets[dir]; ok {
+		return loaded, nil
+	}
+
+	ts, err := tileset.Load(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errorf(http.StatusNotFound, "no tileset named %q", name)
+	}
+	if err != nil {
+		return nil, errorf(http.StatusUnprocessableEntity, "tileset %s is invalid: %v", name, stripDir(err, dir, name))
+	}
+
+	loaded := &loadedTileset{ts: ts, tiles: ts.WfcTiles()}
+	s.tilesets[dir] = loaded
+	return loaded, nil
+}
+
+// Returns the directory of a named tileset, refusing names that leave the tileset or upload directory
+func (s *Server) tilesetPath(name string) (string, error) {
+	if name == "" {
+		return "", errorf(http.StatusBadRequest, "no tileset given")
+	}
+	if s.config.UploadDir != "" && isUploadName(name) {
+		return filepath.Join(s.config.UploadDir, name), nil
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if s.config.TilesetDir == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", errorf(http.StatusNotFound, "no tileset named %q", name)
+	}
+	return filepath.Join(s.config.TilesetDir, clean), nil
+}
+
+// Returns the name of every tileset that can be generated with, the tileset directory's first
+func (s *Server) tilesetNames() ([]string, error) {
+	names := []string{}
+	if s.config.TilesetDir != "" {
+		err := filepath.WalkDir(s.config.TilesetDir, func(dirPath string, entry os.DirEntry, err error) error {
+			if err != nil || !entry.IsDir() {
+				return err
+			}
+			if _, _, err := tileset.FindConfig(dirPath); err != nil {
+				return nil
+			}
+
+			rel, err := filepath.Rel(s.config.TilesetDir, dirPath)
+			if err != nil {
+				return err
+			}
+			names = append(names, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, errorf(http.StatusInternalServerError, "failed to list tilesets: %v", err)
+		}
+	}
+
+	if s.config.UploadDir != "" {
+		entries, err := os.ReadDir(s.config.UploadDir)
+		if err != nil {
+			return nil, errorf(http.StatusInternalServerError, "failed to list uploaded tilesets: %v", err)
+		}
+		var uploads []string
+		for _, entry := range entries {
+			if entry.IsDir() && isUploadName(entry.Name()) {
+				uploads = append(uploads, entry.Name())
+			}
+		}
+		sort.Strings(uploads)
+		names = append(names, uploads...)
+	}
+	return names, nil
+}
+
+// Describes how a generation ended for the log, failures already give their seed
+func outcome(solver *wfc.Solver, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("done, seed %d", solver.Seed())
+}
+
+// Replaces the server's path to a tileset with its name in an error for the client
+func stripDir(err error, dir, name string) string {
+	return strings.ReplaceAll(err.Error(), dir, name)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.status
+	}
+	writeJSON(w, status, errorResponse{err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("failed to write response with error %v", err)
+	}
+}
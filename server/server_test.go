@@ -0,0 +1,328 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"wavefunctioncollapse/mapfile"
+)
+
+func newTestServer(t *testing.T, config Config) *Server {
+	t.Helper()
+	if config.TilesetDir == "" {
+		config.TilesetDir = "../assets"
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	return s
+}
+
+func post(s *Server, target string, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body)))
+	return rec
+}
+
+func Test_Server_generateJSON(t *testing.T) {
+	s := newTestServer(t, Config{})
+	body := []byte(`{"tileset": ".", "width": 6, "height": 4, "seed": 9, "constraints": [{"x": 2, "y": 1, "tiles": [0]}]}`)
+
+	rec := post(s, "/generate", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Failed, expected status %v, got %v: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	m, err := mapfile.Decode(rec.Body, mapfile.JSON)
+	if err != nil {
+		t.Fatalf("Failed, expected a JSON map, got %v", err)
+	}
+	if m.Width != 6 || m.Height != 4 || m.Seed != 9 || m.Tileset != "." {
+		t.Errorf("Failed, expected a 6x4 map of tileset . with seed 9, got %dx%d of %s with seed %d", m.Width, m.Height, m.Tileset, m.Seed)
+	}
+	if m.Grid[2][1] != 0 {
+		t.Errorf("Failed, expected the constrained position to be tile 0, got %v", m.Grid[2][1])
+	}
+
+	again, err := mapfile.Decode(post(s, "/generate", body).Body, mapfile.JSON)
+	if err != nil || !reflect.DeepEqual(m.Grid, again.Grid) {
+		t.Errorf("Failed, expected the same map for the same seed, got %v and %v with err %v", m.Grid, again.Grid, err)
+	}
+}
+
+func Test_Server_generatePNG(t *testing.T) {
+	s := newTestServer(t, Config{})
+	rec := post(s, "/generate", []byte(`{"tileset": ".", "width": 3, "height": 2, "seed": 4, "format": "png", "tileSize": 8}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Failed, expected status %v, got %v: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	if rec.Header().Get("Content-Type") != "image/png" || rec.Header().Get("X-Wfc-Seed") != "4" {
+		t.Errorf("Failed, expected a PNG with seed 4, got %v", rec.Header())
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil || img.Bounds().Dx() != 24 || img.Bounds().Dy() != 16 {
+		t.Errorf("Failed, expected a 24x16 image, got %v with err %v", img, err)
+	}
+}
+
+func Test_Server_generateErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		body     string
+		expected int
+	}{
+		{"Unknown tileset", `{"tileset": "nope", "width": 2, "height": 2}`, http.StatusNotFound},
+		{"Outside the tileset directory", `{"tileset": "../wfc", "width": 2, "height": 2}`, http.StatusNotFound},
+		{"No size", `{"tileset": "."}`, http.StatusBadRequest},
+		{"Too many positions", `{"tileset": ".", "width": 200, "height": 200}`, http.StatusRequestEntityTooLarge},
+		{"Too many pixels", `{"tileset": ".", "width": 100, "height": 100, "format": "png"}`, http.StatusRequestEntityTooLarge},
+		{"Tile larger than the limit", `{"tileset": ".", "width": 2, "height": 2, "format": "png", "tileSize": 4294967296}`, http.StatusBadRequest},
+		{"Tile area overflowing", `{"tileset": ".", "width": 1, "height": 1, "format": "png", "tileSize": 3037000500}`, http.StatusBadRequest},
+		{"Too many attempts", `{"tileset": ".", "width": 2, "height": 2, "attempts": 100}`, http.StatusBadRequest},
+		{"Constraint outside the grid", `{"tileset": ".", "width": 2, "height": 2, "constraints": [{"x": 2, "y": 0, "tiles": [0]}]}`, http.StatusBadRequest},
+		{"Constraint on an unknown tile", `{"tileset": ".", "width": 2, "height": 2, "constraints": [{"x": 0, "y": 0, "tiles": [99]}]}`, http.StatusBadRequest},
+		{"Unsatisfiable", `{"tileset": ".", "width": 2, "height": 2, "constraints": [{"x": 0, "y": 0, "tiles": []}]}`, http.StatusUnprocessableEntity},
+		{"Unknown format", `{"tileset": ".", "width": 2, "height": 2, "format": "gif"}`, http.StatusBadRequest},
+		{"Unknown field", `{"tileset": ".", "width": 2, "height": 2, "depth": 2}`, http.StatusBadRequest},
+		{"Not JSON", `tileset`, http.StatusBadRequest},
+	}
+
+	s := newTestServer(t, Config{MaxPixels: 1000})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := post(s, "/generate", []byte(tc.body))
+			if rec.Code != tc.expected {
+				t.Errorf("Failed, expected status %v, got %v: %s", tc.expected, rec.Code, rec.Body)
+			}
+
+			var res errorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil || res.Error == "" || strings.Contains(res.Error, "../assets") {
+				t.Errorf("Failed, expected an error without the server's paths, got %+v with err %v", res, err)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/generate", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Failed, expected status %v for GET, got %v", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func Test_Server_busy(t *testing.T) {
+	s := newTestServer(t, Config{MaxConcurrent: 1, Timeout: 20 * time.Millisecond})
+	// Hold the only slot, as a slow generation would
+	s.slots <- struct{}{}
+
+	rec := post(s, "/generate", []byte(`{"tileset": ".", "width": 2, "height": 2}`))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Failed, expected status %v, got %v: %s", http.StatusServiceUnavailable, rec.Code, rec.Body)
+	}
+
+	<-s.slots
+	if rec := post(s, "/generate", []byte(`{"tileset": ".", "width": 2, "height": 2}`)); rec.Code != http.StatusOK {
+		t.Errorf("Failed, expected status %v once the slot is free, got %v: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+}
+
+// Returns a zip of the assets tileset, inside a directory if dir isn't empty
+func zipAssets(t *testing.T, dir string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	entries, err := os.ReadDir("../assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("../assets", entry.Name()))
+		if err != nil {
+			t.Fatalf("Failed, expected no error, got %v", err)
+		}
+		file, err := archive.Create(dir + entry.Name())
+		if err != nil {
+			t.Fatalf("Failed, expected no error, got %v", err)
+		}
+		file.Write(data)
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	return buf.Bytes()
+}
+
+// Returns a zip of a single tile tileset named by the config, with blank.png and any other files given
+func zipTileset(t *testing.T, config string, files map[string][]byte) []byte {
+	t.Helper()
+	blank, err := os.ReadFile("../assets/blank.png")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	contents := map[string][]byte{"config.json": []byte(config), "blank.png": blank}
+	for name, data := range files {
+		contents[name] = data
+	}
+	for name, data := range contents {
+		file, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("Failed, expected no error, got %v", err)
+		}
+		file.Write(data)
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	return buf.Bytes()
+}
+
+// Returns a tiny PNG whose header claims it's width by height, far too large to decode
+func hugePNG(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	// The IHDR chunk follows the 8 byte signature, its length and type, with a CRC over its type and data
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], width)
+	binary.BigEndian.PutUint32(data[20:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func Test_Server_upload(t *testing.T) {
+	s := newTestServer(t, Config{UploadDir: t.TempDir()})
+
+	rec := post(s, "/tilesets", zipAssets(t, "roads/"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Failed, expected status %v, got %v: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var uploaded map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&uploaded); err != nil || !isUploadName(uploaded["tileset"]) {
+		t.Fatalf("Failed, expected the uploaded tileset's name, got %v with err %v", uploaded, err)
+	}
+	name := uploaded["tileset"]
+
+	if rec := post(s, "/generate", []byte(`{"tileset": "`+name+`", "width": 3, "height": 3}`)); rec.Code != http.StatusOK {
+		t.Errorf("Failed, expected to generate with the upload, got %v: %s", rec.Code, rec.Body)
+	}
+
+	if rec := post(s, "/tilesets", zipAssets(t, "roads/")); !strings.Contains(rec.Body.String(), name) {
+		t.Errorf("Failed, expected the same name uploading the same archive again, got %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tilesets", nil))
+	var listed map[string][]string
+	expected := []string{".", "circuit", name}
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil || !reflect.DeepEqual(expected, listed["tilesets"]) {
+		t.Errorf("Failed, expected tilesets %v, got %v with err %v", expected, listed, err)
+	}
+}
+
+func Test_Server_uploadEviction(t *testing.T) {
+	uploadDir := t.TempDir()
+	s := newTestServer(t, Config{UploadDir: uploadDir, MaxUploads: 2})
+
+	var names []string
+	for idx, prefix := range []string{"a/", "b/", "c/"} {
+		rec := post(s, "/tilesets", zipAssets(t, prefix))
+		var uploaded map[string]string
+		if err := json.NewDecoder(rec.Body).Decode(&uploaded); err != nil || rec.Code != http.StatusCreated {
+			t.Fatalf("Failed, expected status %v, got %v with err %v", http.StatusCreated, rec.Code, err)
+		}
+		names = append(names, uploaded["tileset"])
+
+		// Uploads in the same instant would be ordered by name, so space them out
+		at := time.Now().Add(time.Duration(idx-3) * time.Hour)
+		if err := os.Chtimes(filepath.Join(uploadDir, names[idx]), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tilesets", nil))
+	var listed map[string][]string
+	if err := json.NewDecoder(rec.Body).Decode(&listed); err != nil || len(listed["tilesets"]) != 4 {
+		t.Fatalf("Failed, expected the 2 server tilesets and 2 uploads, got %v with err %v", listed, err)
+	}
+	for _, name := range listed["tilesets"] {
+		if name == names[0] {
+			t.Errorf("Failed, expected the oldest upload %s to be removed, got %v", names[0], listed["tilesets"])
+		}
+	}
+
+	if rec := post(s, "/generate", []byte(`{"tileset": "`+names[0]+`", "width": 3, "height": 3}`)); rec.Code != http.StatusNotFound {
+		t.Errorf("Failed, expected status %v for the removed upload, got %v: %s", http.StatusNotFound, rec.Code, rec.Body)
+	}
+	if rec := post(s, "/generate", []byte(`{"tileset": "`+names[2]+`", "width": 3, "height": 3}`)); rec.Code != http.StatusOK {
+		t.Errorf("Failed, expected to generate with the newest upload, got %v: %s", rec.Code, rec.Body)
+	}
+}
+
+func Test_Server_uploadErrors(t *testing.T) {
+	var escaping bytes.Buffer
+	archive := zip.NewWriter(&escaping)
+	file, _ := archive.Create("../config.json")
+	file.Write([]byte(`{}`))
+	archive.Close()
+
+	tileConfig := func(name, atlas string) string {
+		config := `{"version": 2, "settings": {`
+		if atlas != "" {
+			config += `"atlases": [{"image": "` + atlas + `", "tileWidth": 4, "tileHeight": 4}]`
+		}
+		return config + `}, "tiles": [{"name": "` + name + `", "connections": {"left": "AAA", "up": "AAA", "right": "AAA", "down": "AAA"}}]}`
+	}
+
+	testCases := []struct {
+		name     string
+		config   Config
+		body     []byte
+		expected int
+	}{
+		{"Uploads disabled", Config{}, zipAssets(t, ""), http.StatusForbidden},
+		{"Not a zip", Config{UploadDir: t.TempDir()}, []byte("tileset"), http.StatusBadRequest},
+		{"Path outside the tileset", Config{UploadDir: t.TempDir()}, escaping.Bytes(), http.StatusBadRequest},
+		{"Archive too large", Config{UploadDir: t.TempDir(), MaxUploadBytes: 100}, zipAssets(t, ""), http.StatusRequestEntityTooLarge},
+		{"No config", Config{UploadDir: t.TempDir()}, zipAssets(t, "a/b/"), http.StatusUnprocessableEntity},
+		{"Tile outside the tileset", Config{UploadDir: t.TempDir()}, zipTileset(t, tileConfig("../../../../tmp/secret.png", ""), nil), http.StatusUnprocessableEntity},
+		{"Absolute tile path", Config{UploadDir: t.TempDir()}, zipTileset(t, tileConfig("/tmp/secret.png", ""), nil), http.StatusUnprocessableEntity},
+		{"Atlas outside the tileset", Config{UploadDir: t.TempDir()}, zipTileset(t, tileConfig("blank.png", "../atlas.png"), nil), http.StatusUnprocessableEntity},
+		{"Missing image", Config{UploadDir: t.TempDir()}, zipTileset(t, tileConfig("missing.png", ""), nil), http.StatusUnprocessableEntity},
+		{"Image too large", Config{UploadDir: t.TempDir(), MaxImagePixels: 100}, zipAssets(t, ""), http.StatusRequestEntityTooLarge},
+		{"Huge image header", Config{UploadDir: t.TempDir()}, zipTileset(t, tileConfig("huge.png", ""), map[string][]byte{"huge.png": hugePNG(t, 100000, 100000)}), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if rec := post(newTestServer(t, tc.config), "/tilesets", tc.body); rec.Code != tc.expected {
+				t.Errorf("Failed, expected status %v, got %v: %s", tc.expected, rec.Code, rec.Body)
+			}
+		})
+	}
+}
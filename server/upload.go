@@ -0,0 +1,285 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"wavefunctioncollapse/tileset"
+)
+
+// Uploaded tilesets are named by this many hex characters of their archive's hash
+const uploadNameLength = 16
+
+// Most files an uploaded archive can hold
+const maxUploadFiles = 4096
+
+// Unpacks a zip of a tileset from the request body into the upload directory, returning the tileset's name
+// The config can be at the top of the archive or in its only directory. Uploading the same archive twice gives the same name
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) (string, error) {
+	if s.config.UploadDir == "" {
+		return "", errorf(http.StatusForbidden, "uploads are disabled, use one of the server's tilesets")
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.config.MaxUploadBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "", errorf(http.StatusRequestEntityTooLarge, "archive is larger than %d bytes", tooLarge.Limit)
+	}
+	if err != nil {
+		return "", errorf(http.StatusBadRequest, "failed to read archive: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", errorf(http.StatusBadRequest, "expected a zip archive of a tileset: %v", err)
+	}
+	if len(archive.File) > maxUploadFiles {
+		return "", errorf(http.StatusRequestEntityTooLarge, "archive has %d files, more than the %d allowed", len(archive.File), maxUploadFiles)
+	}
+
+	hash := sha256.Sum256(data)
+	name := hex.EncodeToString(hash[:])[:uploadNameLength]
+	dir := filepath.Join(s.config.UploadDir, name)
+	if _, err := os.Stat(dir); err == nil {
+		// Uploading again counts as recent, so it's kept over older uploads
+		now := time.Now()
+		os.Chtimes(dir, now, now)
+		return name, nil
+	}
+
+	// Unpack next to the uploads, so only complete tilesets ever appear under their name
+	tmpDir, err := os.MkdirTemp(s.config.UploadDir, ".upload-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload directory with error %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := unzip(archive, tmpDir, s.config.MaxUploadBytes); err != nil {
+		return "", err
+	}
+
+	root, err := tilesetRoot(tmpDir)
+	if err != nil {
+		return "", err
+	}
+	ts, err := tileset.Load(root)
+	if err != nil {
+		return "", errorf(http.StatusUnprocessableEntity, "tileset is invalid: %s", stripDir(err, root, name))
+	}
+	if err := checkImages(ts, s.config.MaxImagePixels); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(root, dir); err != nil {
+		// Another request may have uploaded the same archive meanwhile
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return name, nil
+		}
+		return "", fmt.Errorf("failed to store upload with error %v", err)
+	}
+	if err := s.evictUploads(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Removes the least recently uploaded tilesets until no more than MaxUploads are kept, never the one just uploaded
+// Generations already running with a removed tileset finish, unless they still need to load its images
+func (s *Server) evictUploads(keep string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.config.UploadDir)
+	if err != nil {
+		return fmt.Errorf("failed to list uploaded tilesets with error %v", err)
+	}
+	type upload struct {
+		name     string
+		uploaded time.Time
+	}
+	var uploads []upload
+	for _, entry := range entries {
+		if !entry.IsDir() || !isUploadName(entry.Name()) || entry.Name() == keep {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		uploads = append(uploads, upload{entry.Name(), info.ModTime()})
+	}
+
+	// The upload just stored takes one of the places
+	excess := len(uploads) + 1 - s.config.MaxUploads
+	if excess <= 0 {
+		return nil
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if !uploads[i].uploaded.Equal(uploads[j].uploaded) {
+			return uploads[i].uploaded.Before(uploads[j].uploaded)
+		}
+		return uploads[i].name < uploads[j].name
+	})
+	for _, evicted := range uploads[:excess] {
+		dir := filepath.Join(s.config.UploadDir, evicted.name)
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove upload %s with error %v", evicted.name, err)
+		}
+		delete(s.tilesets, dir)
+		log.Printf("removed uploaded tileset %s to make room", evicted.name)
+	}
+	return nil
+}
+
+// Writes every file in an archive under dir, refusing paths outside it and more than maxBytes unpacked
+func unzip(archive *zip.Reader, dir string, maxBytes int64) error {
+	remaining := maxBytes
+	for _, file := range archive.File {
+		name := filepath.Clean(filepath.FromSlash(file.Name))
+		if leavesDir(name) {
+			return errorf(http.StatusBadRequest, "archive path %q leaves the tileset", file.Name)
+		}
+
+		filePath := filepath.Join(dir, name)
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(filePath, 0755); err != nil {
+				return fmt.Errorf("failed to unpack %s with error %v", file.Name, err)
+			}
+			continue
+		}
+
+		written, err := unzipFile(file, filePath, remaining)
+		if err != nil {
+			return err
+		}
+		remaining -= written
+	}
+	return nil
+}
+
+// Reports whether a cleaned relative path is absolute or climbs out of its directory
+func leavesDir(name string) bool {
+	return filepath.IsAbs(name) || strings.HasPrefix(name, string(filepath.Separator)) ||
+		name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator))
+}
+
+// Checks every image the tileset's tiles and atlases name is inside its directory and no more than maxPixels
+// Only the image headers are read, so an image too large to decode is refused before it's decoded
+func checkImages(ts *tileset.Tileset, maxPixels int) error {
+	files := make([]string, 0, len(ts.Tiles)+len(ts.Settings.Atlases))
+	for _, tile := range ts.Tiles {
+		file, _, _ := strings.Cut(tile.Name, "#")
+		files = append(files, file)
+	}
+	for _, atlas := range ts.Settings.Atlases {
+		files = append(files, atlas.Image)
+	}
+
+	checked := make(map[string]bool, len(files))
+	for _, file := range files {
+		if checked[file] {
+			continue
+		}
+		checked[file] = true
+
+		if leavesDir(filepath.Clean(filepath.FromSlash(file))) {
+			return errorf(http.StatusUnprocessableEntity, "tileset image %q is outside the tileset", file)
+		}
+		size, err := imageSize(tileset.JoinPath(ts.Dir(), file))
+		if err != nil {
+			return errorf(http.StatusUnprocessableEntity, "tileset image %q can't be read: %v", file, err)
+		}
+		if int64(size.X)*int64(size.Y) > int64(maxPixels) {
+			return errorf(http.StatusRequestEntityTooLarge, "tileset image %q is %dx%d, more than the %d pixels allowed", file, size.X, size.Y, maxPixels)
+		}
+	}
+	return nil
+}
+
+// Returns the size of an image file from its header, without decoding the pixels
+// Errors don't include the path, so they don't reveal where the server keeps tilesets
+func imageSize(imgPath string) (image.Point, error) {
+	imgReader, err := os.Open(imgPath)
+	if os.IsNotExist(err) {
+		return image.Point{}, errors.New("no such file")
+	}
+	if err != nil {
+		return image.Point{}, errors.New("failed to open it")
+	}
+	defer imgReader.Close()
+
+	config, _, err := image.DecodeConfig(imgReader)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return image.Pt(config.Width, config.Height), nil
+}
+
+// Writes a single file from an archive, failing if it unpacks to more than maxBytes
+// The sizes in the archive's headers can't be trusted, so the bytes written are counted
+func unzipFile(file *zip.File, filePath string, maxBytes int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to unpack %s with error %v", file.Name, err)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return 0, errorf(http.StatusBadRequest, "failed to read %s from the archive: %v", file.Name, err)
+	}
+	defer src.Close()
+
+	dst, err := os.Create(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unpack %s with error %v", file.Name, err)
+	}
+	defer dst.Close()
+
+	written, err := io.Copy(dst, io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return written, errorf(http.StatusBadRequest, "failed to unpack %s: %v", file.Name, err)
+	}
+	if written > maxBytes {
+		return written, errorf(http.StatusRequestEntityTooLarge, "archive unpacks to more than %d bytes", maxBytes)
+	}
+	return written, nil
+}
+
+// Returns the directory holding the tileset's config, the unpacked archive or its only directory
+func tilesetRoot(dir string) (string, error) {
+	if _, _, err := tileset.FindConfig(dir); err == nil {
+		return dir, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload with error %v", err)
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root := filepath.Join(dir, entries[0].Name())
+		if _, _, err := tileset.FindConfig(root); err == nil {
+			return root, nil
+		}
+	}
+	return "", errorf(http.StatusUnprocessableEntity, "no config.json, config.yaml or config.toml at the top of the archive or in its only directory")
+}
+
+// Reports whether a tileset name is one given to an upload
+func isUploadName(name string) bool {
+	if len(name) != uploadNameLength {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil && strings.ToLower(name) == name
+}
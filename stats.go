@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+var statsCommand = command{
+	name:    "stats",
+	args:    "<tileset dir>",
+	summary: "print generation statistics over many runs",
+	help: "Generates -runs maps one after another and prints the spread of each solver statistic: steps, backtracks,\n" +
+		"contradictions, the deepest backtracking history, propagations, time spent selecting positions and propagating,\n" +
+		"and how often each tile was used. Seeds count up from -seed, or are random if it's 0, so two tilesets or sets of\n" +
+		"limits can be compared on the same seeds. -json prints the summary as JSON. Failed runs are included in the figures.",
+	run: runStats,
+}
+
+// Width of the bar drawn for the most used tile in the text summary
+const usageBarWidth = 30
+
+// StatsSummary is the spread of the solver statistics over many runs, printed by the stats command
+type StatsSummary struct {
+	Tileset   string       `json:"tileset"`
+	Width     int          `json:"width"`
+	Height    int          `json:"height"`
+	Runs      int          `json:"runs"`
+	Failed    int          `json:"failed"`
+	Metrics   []Metric     `json:"metrics"`
+	TileUsage []TileUsage  `json:"tileUsage"` // in the tileset's order
+	Failures  []RunFailure `json:"failures,omitempty"`
+}
+
+// Metric is the spread of one statistic over every run, times are in milliseconds
+type Metric struct {
+	Name   string  `json:"name"`
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// TileUsage is how often a tile was picked over every run
+type TileUsage struct {
+	Id    int     `json:"id"`
+	Tile  string  `json:"tile"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"` // fraction of every collapsed position
+}
+
+// RunFailure is a run that stopped before finishing its map
+type RunFailure struct {
+	Seed  int64  `json:"seed"`
+	Error string `json:"error"`
+}
+
+// Statistics of a single run, with the wall-clock time it took
+type runResult struct {
+	wfc.Stats
+	seed     int64
+	duration time.Duration
+	err      error
+}
+
+func runStats(flags *flag.FlagSet, args []string) int {
+	width := flags.Int("width", 0, "width of grid to collapse, defaults to the tileset's width or 32")
+	height := flags.Int("height", 0, "height of grid to collapse, defaults to the tileset's height or 18")
+	runs := flags.Int("runs", 100, "number of maps to generate")
+	seed := flags.Int64("seed", 0, "seed of the first run, each run after uses the next seed, 0 for random seeds")
+	asJSON := flags.Bool("json", false, "print the summary as JSON")
+	gen := generationFlags{}
+	flags.DurationVar(&gen.timeout, "timeout", time.Minute, "give up a run after this long, 0 for no limit")
+	flags.IntVar(&gen.maxSteps, "max-steps", 0, "give up a run after this many steps, 0 for no limit")
+	flags.IntVar(&gen.maxBacktracks, "max-backtracks", 0, "give up a run after this many backtracks, 0 for no limit")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	if *runs <= 0 {
+		fmt.Fprintf(flags.Output(), "-runs must be positive, got %d\n", *runs)
+		return exitUsage
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	tiles := ts.WfcTiles()
+	gridWidth, gridHeight := gridSize(ts.Settings, *width, *height)
+	if err := checkGrid(ts, gridWidth, gridHeight, nil); err != nil {
+		return fail(err)
+	}
+	// Runs one at a time, so they don't compete for the CPU and skew the timings
+	results := make([]runResult, 0, *runs)
+	for _, runSeed := range batchSeeds(*seed, *runs) {
+		opts := gen.options()
+		opts.Seed = runSeed
+		start := time.Now()
+		_, stats, err := wfc.CollapseStats(context.Background(), tiles, gridWidth, gridHeight, opts)
+		results = append(results, runResult{Stats: stats, seed: runSeed, duration: time.Since(start), err: err})
+	}
+
+	summary := summarise(ts, gridWidth, gridHeight, results)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(summary)
+	} else {
+		err = summary.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// Returns the spread of every statistic over the runs
+func summarise(ts *tileset.Tileset, width, height int, results []runResult) *StatsSummary {
+	summary := &StatsSummary{Tileset: ts.Dir(), Width: width, Height: height, Runs: len(results)}
+
+	metrics := []struct {
+		name  string
+		value func(run runResult) float64
+	}{
+		{"steps", func(run runResult) float64 { return float64(run.Steps) }},
+		{"backtracks", func(run runResult) float64 { return float64(run.Backtracks) }},
+		{"contradictions", func(run runResult) float64 { return float64(run.Contradictions) }},
+		{"max depth", func(run runResult) float64 { return float64(run.MaxDepth) }},
+		{"propagations", func(run runResult) float64 { return float64(run.Propagations) }},
+		{"eliminated", func(run runResult) float64 { return float64(run.Eliminated) }},
+		{"selection ms", func(run runResult) float64 { return milliseconds(run.SelectionTime) }},
+		{"propagation ms", func(run runResult) float64 { return milliseconds(run.PropagationTime) }},
+		{"total ms", func(run runResult) float64 { return milliseconds(run.duration) }},
+	}
+	values := make([]float64, len(results))
+	for _, metric := range metrics {
+		for idx, run := range results {
+			values[idx] = metric.value(run)
+		}
+		summary.Metrics = append(summary.Metrics, spread(metric.name, values))
+	}
+
+	usage := make(map[int]int)
+	collapsed := 0
+	for _, run := range results {
+		if run.err != nil {
+			summary.Failed++
+			summary.Failures = append(summary.Failures, RunFailure{Seed: run.seed, Error: run.err.Error()})
+		}
+		for id, count := range run.TileUsage {
+			usage[id] += count
+			collapsed += count
+		}
+	}
+	for id, tile := range ts.Tiles {
+		entry := TileUsage{Id: id, Tile: tile.Label(), Count: usage[id]}
+		if collapsed > 0 {
+			entry.Share = float64(entry.Count) / float64(collapsed)
+		}
+		summary.TileUsage = append(summary.TileUsage, entry)
+	}
+
+	return summary
+}
+
+// Returns the mean, median, 95th percentile and range of the values, which are sorted in place
+func spread(name string, values []float64) Metric {
+	sort.Float64s(values)
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return Metric{
+		Name:   name,
+		Mean:   total / float64(len(values)),
+		Median: percentile(values, 0.5),
+		P95:    percentile(values, 0.95),
+		Min:    values[0],
+		Max:    values[len(values)-1],
+	}
+}
+
+// Returns the nearest rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// Writes the summary as a table of the metrics, then a bar chart of tile usage
+func (summary *StatsSummary) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s, %d runs of %dx%d, %d failed\n\n", summary.Tileset, summary.Runs, summary.Width, summary.Height, summary.Failed)
+	fmt.Fprintf(&b, "%-16s %12s %12s %12s %12s %12s\n", "", "mean", "median", "p95", "min", "max")
+	for _, metric := range summary.Metrics {
+		fmt.Fprintf(&b, "%-16s %12.2f %12.2f %12.2f %12.2f %12.2f\n", metric.Name, metric.Mean, metric.Median, metric.P95, metric.Min, metric.Max)
+	}
+
+	most, longest := 0.0, 0
+	for _, usage := range summary.TileUsage {
+		if usage.Share > most {
+			most = usage.Share
+		}
+		if len(usage.Tile) > longest {
+			longest = len(usage.Tile)
+		}
+	}
+	fmt.Fprintf(&b, "\ntile usage:\n")
+	for _, usage := range summary.TileUsage {
+		bar := 0
+		if most > 0 {
+			bar = int(math.Round(usageBarWidth * usage.Share / most))
+		}
+		fmt.Fprintf(&b, "  %-*s %6.2f%%  %s\n", longest, usage.Tile, usage.Share*100, strings.Repeat("#", bar))
+	}
+
+	if len(summary.Failures) > 0 {
+		fmt.Fprintf(&b, "\nfailed runs:\n")
+		for _, failure := range summary.Failures {
+			fmt.Fprintf(&b, "  seed %d: %s\n", failure.Seed, failure.Error)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
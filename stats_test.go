@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+func Test_spread(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []float64
+		expected Metric
+	}{
+		{"single value", []float64{4}, Metric{Name: "single value", Mean: 4, Median: 4, P95: 4, Min: 4, Max: 4}},
+		{"unsorted values", []float64{3, 1, 2, 4}, Metric{Name: "unsorted values", Mean: 2.5, Median: 2, P95: 4, Min: 1, Max: 4}},
+		{"long tail", []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 81}, Metric{Name: "long tail", Mean: 5, Median: 1, P95: 1, Min: 1, Max: 81}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spread(tt.name, tt.values); got != tt.expected {
+				t.Errorf("Failed, expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func Test_summarise(t *testing.T) {
+	ts, err := tileset.Load("assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	results := []runResult{
+		{Stats: wfc.Stats{Steps: 4, TileUsage: map[int]int{0: 3, 1: 1}}, seed: 1, duration: 2 * time.Millisecond},
+		{Stats: wfc.Stats{Steps: 2, TileUsage: map[int]int{0: 1}}, seed: 2, duration: 4 * time.Millisecond, err: errors.New("step limit reached")},
+	}
+	summary := summarise(ts, 2, 2, results)
+
+	if summary.Runs != 2 || summary.Failed != 1 || len(summary.Failures) != 1 || summary.Failures[0].Seed != 2 {
+		t.Errorf("Failed, expected 2 runs with seed 2 failing, got %+v", summary)
+	}
+	if steps := summary.Metrics[0]; steps.Name != "steps" || steps.Mean != 3 || steps.Max != 4 {
+		t.Errorf("Failed, expected steps with mean 3 and max 4, got %+v", steps)
+	}
+	if total := summary.Metrics[len(summary.Metrics)-1]; total.Name != "total ms" || total.Mean != 3 {
+		t.Errorf("Failed, expected a total time with mean 3ms, got %+v", total)
+	}
+
+	if len(summary.TileUsage) != len(ts.Tiles) || summary.TileUsage[0].Count != 4 || summary.TileUsage[0].Share != 0.8 || summary.TileUsage[2].Count != 0 {
+		t.Errorf("Failed, expected tile 0 used for 80%% of positions and unused tiles listed, got %+v", summary.TileUsage)
+	}
+
+	var text strings.Builder
+	if err := summary.WriteText(&text); err != nil || !strings.Contains(text.String(), "seed 2: step limit reached") {
+		t.Errorf("Failed, expected the text summary to list the failed run, got %s with err %v", text.String(), err)
+	}
+}
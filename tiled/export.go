@@ -0,0 +1,234 @@
+package tiled
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Export is a tileset converted for Tiled, with where each wfc tile ID ended up
+type Export struct {
+	Tileset *Tileset
+	Gids    []uint32 // ID within the Tiled tileset and flip bits for each wfc tile ID, add a map's first GID to place it
+}
+
+// Converts a tileset to a Tiled image collection, with image paths relative to outDir
+// Variants of an image become a single Tiled tile, maps place them with flip bits instead
+// Connectors are kept as left, up, right and down properties of each tile, so it can be imported again
+func NewExport(ts *tileset.Tileset, outDir string) (*Export, error) {
+	loader := ts.Loader()
+	export := &Export{
+		Tileset: &Tileset{
+			Version:         formatVersion,
+			Name:            tilesetName(ts),
+			Transformations: &Transformations{HFlip: 1, VFlip: 1, Rotate: 1, PreferUntransformed: 1},
+		},
+		Gids: make([]uint32, len(ts.Tiles)),
+	}
+
+	// Every tile with the same image shares a Tiled tile, described by the untransformed tile
+	bases := make(map[string]int)
+	for id, tile := range ts.Tiles {
+		tiledId, ok := bases[tile.Name]
+		if !ok {
+			tiledId = len(export.Tileset.Tiles)
+			bases[tile.Name] = tiledId
+
+			tiledTile, err := exportTile(ts, loader, tile, outDir)
+			if err != nil {
+				return nil, err
+			}
+			tiledTile.Id = tiledId
+			export.Tileset.Tiles = append(export.Tileset.Tiles, tiledTile)
+
+			// Tiled sizes a collection by its largest tile
+			if tiledTile.Width > export.Tileset.TileWidth {
+				export.Tileset.TileWidth = tiledTile.Width
+			}
+			if tiledTile.Height > export.Tileset.TileHeight {
+				export.Tileset.TileHeight = tiledTile.Height
+			}
+		}
+
+		export.Gids[id] = uint32(tiledId) | Flags(tile.Transform)
+	}
+	export.Tileset.TileCount = len(export.Tileset.Tiles)
+
+	// Only atlas tiles need their area in the image, tiles using the whole image leave it unset
+	for idx, tiledTile := range export.Tileset.Tiles {
+		if tiledTile.X == 0 && tiledTile.Y == 0 && tiledTile.Width == tiledTile.Image.Width && tiledTile.Height == tiledTile.Image.Height {
+			export.Tileset.Tiles[idx].Width, export.Tileset.Tiles[idx].Height = 0, 0
+		}
+	}
+
+	return export, nil
+}
+
+// Returns the Tiled tile for an image, using the untransformed tile for its properties if the tileset has one
+func exportTile(ts *tileset.Tileset, loader *tileset.TileLoader, tile tileset.Tile, outDir string) (Tile, error) {
+	base := tile
+	base.Connections = tile.Transform.Inverse().Connections(tile.Connections)
+	for _, other := range ts.Tiles {
+		if other.Name == tile.Name && other.Transform == "" {
+			base = other
+			break
+		}
+	}
+
+	file, rect, err := loader.Source(tile.Name)
+	if err != nil {
+		return Tile{}, err
+	}
+	size, err := loader.ImageSize(file)
+	if err != nil {
+		return Tile{}, err
+	}
+
+	source, err := relativePath(tileset.JoinPath(ts.Dir(), file), outDir)
+	if err != nil {
+		return Tile{}, err
+	}
+
+	tiledTile := Tile{
+		X:      rect.Min.X,
+		Y:      rect.Min.Y,
+		Width:  rect.Dx(),
+		Height: rect.Dy(),
+		Image:  &Image{Source: source, Width: size.X, Height: size.Y},
+	}
+
+	for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+		if connector, ok := base.Connections[dir]; ok {
+			tiledTile.Properties = append(tiledTile.Properties, Property{Name: tileset.DirectionNames[dir], Value: connector})
+		}
+	}
+	if base.DisplayName != "" {
+		tiledTile.Properties = append(tiledTile.Properties, Property{Name: propertyDisplayName, Value: base.DisplayName})
+	}
+	if base.Weight != 0 {
+		tiledTile.Properties = append(tiledTile.Properties, Property{Name: propertyWeight, Type: "float", Value: strconv.FormatFloat(base.Weight, 'g', -1, 64)})
+	}
+	if len(base.Tags) > 0 {
+		tiledTile.Properties = append(tiledTile.Properties, Property{Name: propertyTags, Value: strings.Join(base.Tags, ",")})
+	}
+	if base.Symmetry != "" {
+		tiledTile.Properties = append(tiledTile.Properties, Property{Name: propertySymmetry, Value: base.Symmetry})
+	}
+	if len(base.Variants) > 0 {
+		variants := make([]string, len(base.Variants))
+		for idx, variant := range base.Variants {
+			variants[idx] = string(variant)
+		}
+		tiledTile.Properties = append(tiledTile.Properties, Property{Name: propertyVariants, Value: strings.Join(variants, ",")})
+	}
+
+	return tiledTile, nil
+}
+
+// Writes the tileset to a .tsx file, with image paths relative to it
+func WriteTileset(ts *tileset.Tileset, tsxPath string) (*Export, error) {
+	export, err := NewExport(ts, filepath.Dir(tsxPath))
+	if err != nil {
+		return nil, err
+	}
+	return export, writeXML(tsxPath, export.Tileset)
+}
+
+// Writes a generated result to a .tmx map, with its tileset written next to it as <map name>.tsx
+// Undecided positions are left empty
+func WriteMap(ts *tileset.Tileset, result [][]int, seed int64, tmxPath string) error {
+	tsxPath := strings.TrimSuffix(tmxPath, filepath.Ext(tmxPath)) + ".tsx"
+	export, err := WriteTileset(ts, tsxPath)
+	if err != nil {
+		return err
+	}
+
+	tiledMap, err := export.Map(result, filepath.Base(tsxPath))
+	if err != nil {
+		return err
+	}
+	if seed != 0 {
+		tiledMap.Properties = []Property{{Name: "seed", Value: strconv.FormatInt(seed, 10)}}
+	}
+
+	return writeXML(tmxPath, tiledMap)
+}
+
+// Returns a map of a generated result using the exported tileset, found at tsxSource relative to the map
+func (export *Export) Map(result [][]int, tsxSource string) (*Map, error) {
+	if len(result) == 0 {
+		return nil, fmt.Errorf("nothing to export, the map is empty")
+	}
+
+	const firstGid = 1
+	width, height := len(result), len(result[0])
+	var data strings.Builder
+	data.WriteString("\n")
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gid := uint32(0)
+			if id := result[x][y]; id != wfc.Undecided {
+				if id < 0 || id >= len(export.Gids) {
+					return nil, fmt.Errorf("tile %d at (%d, %d) isn't in the tileset", id, x, y)
+				}
+				// Flip bits are the top bits, so adding the first GID only changes the tile
+				gid = export.Gids[id] + firstGid
+			}
+
+			data.WriteString(strconv.FormatUint(uint64(gid), 10))
+			if x < width-1 || y < height-1 {
+				data.WriteString(",")
+			}
+		}
+		data.WriteString("\n")
+	}
+
+	return &Map{
+		Version:      formatVersion,
+		Orientation:  "orthogonal",
+		RenderOrder:  "right-down",
+		Width:        width,
+		Height:       height,
+		TileWidth:    export.Tileset.TileWidth,
+		TileHeight:   export.Tileset.TileHeight,
+		NextLayerId:  2,
+		NextObjectId: 1,
+		Tilesets:     []TilesetRef{{FirstGid: firstGid, Source: tsxSource}},
+		Layers: []Layer{{
+			Id:     1,
+			Name:   "wfc",
+			Width:  width,
+			Height: height,
+			Data:   Data{Encoding: "csv", Text: data.String()},
+		}},
+	}, nil
+}
+
+// Returns the name of the Tiled tileset, the tileset's name or its directory
+func tilesetName(ts *tileset.Tileset) string {
+	if ts.Settings.Name != "" {
+		return ts.Settings.Name
+	}
+	return filepath.Base(ts.Dir())
+}
+
+// Returns filePath relative to dir, slash separated as Tiled expects
+func relativePath(filePath, dir string) (string, error) {
+	absFile, err := filepath.Abs(filePath)
+	if err != nil {
+		return "", err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(absDir, absFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to find %s relative to %s with error %v", filePath, dir, err)
+	}
+	return filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,201 @@
+package tiled
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Most tiles a single image tileset can import, maps store tile IDs as uint16 with the largest meaning undecided
+const maxImportTiles = 1<<16 - 1
+
+// Reads a Tiled .tsx tileset
+func ReadTileset(tsxPath string) (*Tileset, error) {
+	data, err := os.ReadFile(tsxPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s with error %v", tsxPath, err)
+	}
+
+	var tiledTileset Tileset
+	if err := xml.Unmarshal(data, &tiledTileset); err != nil {
+		return nil, fmt.Errorf("failed to decode %s with error %v", tsxPath, err)
+	}
+	return &tiledTileset, nil
+}
+
+// Converts a Tiled tileset read from tsxPath to a wfc tileset saved in outDir, image paths are made relative to outDir
+// Each tile's connectors come from its left, up, right and down properties, and weight, tags, symmetry,
+// variants and displayName properties are kept. Tiles missing connectors are still imported so validate can report them
+func Import(tsxPath, outDir string) (*tileset.Tileset, error) {
+	tiledTileset, err := ReadTileset(tsxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tsxDir := filepath.Dir(tsxPath)
+	imagePath := func(source string) (string, error) {
+		return relativePath(tileset.JoinPath(tsxDir, source), outDir)
+	}
+
+	ts := tileset.New(outDir, nil)
+	ts.Settings.Name = tiledTileset.Name
+	ts.Settings.TileSize = tiledTileset.TileWidth
+
+	// A single image tileset is an atlas, its tiles are numbered the same way
+	if tiledTileset.Image != nil {
+		image, err := imagePath(tiledTileset.Image.Source)
+		if err != nil {
+			return nil, err
+		}
+		ts.Settings.Atlases = []tileset.Atlas{{
+			Image:      image,
+			TileWidth:  tiledTileset.TileWidth,
+			TileHeight: tiledTileset.TileHeight,
+			Margin:     tiledTileset.Margin,
+			Spacing:    tiledTileset.Spacing,
+		}}
+
+		// Tiled only writes <tile> elements for tiles with properties, so every tile in the image is imported
+		// The tile count and image size are both untrusted, so neither can ask for more tiles than the other allows
+		count := tiledTileset.TileCount
+		if capacity := atlasTileCount(tiledTileset); capacity > 0 {
+			if count > capacity {
+				return nil, fmt.Errorf("%s has a tile count of %d, more than the %d tiles its image holds", tsxPath, count, capacity)
+			}
+			if count <= 0 {
+				count = capacity
+			}
+		}
+		if count > maxImportTiles {
+			return nil, fmt.Errorf("%s has %d tiles, more than the %d that can be imported", tsxPath, count, maxImportTiles)
+		}
+		properties := make(map[int]Tile, len(tiledTileset.Tiles))
+		for _, tiledTile := range tiledTileset.Tiles {
+			if tiledTile.Id < 0 || tiledTile.Id >= count {
+				return nil, fmt.Errorf("tile %d in %s is outside its %d tiles", tiledTile.Id, tsxPath, count)
+			}
+			properties[tiledTile.Id] = tiledTile
+		}
+
+		for id := 0; id < count; id++ {
+			tiledTile, ok := properties[id]
+			if !ok {
+				tiledTile = Tile{Id: id}
+			}
+			tile, err := importTile(tiledTile)
+			if err != nil {
+				return nil, err
+			}
+			tile.Name = fmt.Sprintf("%s#%d", image, id)
+			ts.Tiles = append(ts.Tiles, tile)
+		}
+		return ts, nil
+	}
+
+	atlases := make(map[string]int)
+	for _, tiledTile := range tiledTileset.Tiles {
+		if tiledTile.Image == nil {
+			return nil, fmt.Errorf("tile %d in %s has no image", tiledTile.Id, tsxPath)
+		}
+
+		tile, err := importTile(tiledTile)
+		if err != nil {
+			return nil, err
+		}
+		if tile.Name, err = imagePath(tiledTile.Image.Source); err != nil {
+			return nil, err
+		}
+
+		// Tiles using part of an image are read from it as an atlas
+		if tiledTile.Width > 0 && (tiledTile.Width != tiledTile.Image.Width || tiledTile.Height != tiledTile.Image.Height) {
+			idx, ok := atlases[tile.Name]
+			if !ok {
+				idx = len(ts.Settings.Atlases)
+				atlases[tile.Name] = idx
+				ts.Settings.Atlases = append(ts.Settings.Atlases, tileset.Atlas{Image: tile.Name, TileWidth: tiledTile.Width, TileHeight: tiledTile.Height})
+			}
+
+			atlas := ts.Settings.Atlases[idx]
+			if tiledTile.Width != atlas.TileWidth || tiledTile.Height != atlas.TileHeight ||
+				tiledTile.X%atlas.TileWidth != 0 || tiledTile.Y%atlas.TileHeight != 0 || tiledTile.Image.Width == 0 {
+				return nil, fmt.Errorf("tile %d in %s uses an area of %s that isn't on a grid of %dx%d tiles, only evenly sliced images are supported",
+					tiledTile.Id, tsxPath, tile.Name, atlas.TileWidth, atlas.TileHeight)
+			}
+
+			columns := tiledTile.Image.Width / atlas.TileWidth
+			tile.Name = fmt.Sprintf("%s#%d", tile.Name, tiledTile.Y/atlas.TileHeight*columns+tiledTile.X/atlas.TileWidth)
+		}
+
+		ts.Tiles = append(ts.Tiles, tile)
+	}
+
+	return ts, nil
+}
+
+// Returns the number of tiles a single image tileset slices its image into, 0 if the image size isn't given
+// Columns come from the image size rather than the tileset's columns, the same as slicing the atlas
+func atlasTileCount(tiledTileset *Tileset) int {
+	tileWidth, tileHeight := tiledTileset.TileWidth+tiledTileset.Spacing, tiledTileset.TileHeight+tiledTileset.Spacing
+	if tileWidth <= 0 || tileHeight <= 0 {
+		return 0
+	}
+
+	columns := (tiledTileset.Image.Width - 2*tiledTileset.Margin + tiledTileset.Spacing) / tileWidth
+	rows := (tiledTileset.Image.Height - 2*tiledTileset.Margin + tiledTileset.Spacing) / tileHeight
+	if columns <= 0 || rows <= 0 {
+		return 0
+	}
+	return columns * rows
+}
+
+// Returns a wfc tile from a Tiled tile's properties, without its name
+func importTile(tiledTile Tile) (tileset.Tile, error) {
+	tile := tileset.Tile{Connections: tileset.Connections{}}
+	for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+		if connector, ok := tiledTile.Property(tileset.DirectionNames[dir]); ok {
+			tile.Connections[dir] = connector
+		}
+	}
+
+	tile.DisplayName, _ = tiledTile.Property(propertyDisplayName)
+	tile.Symmetry, _ = tiledTile.Property(propertySymmetry)
+
+	if weight, ok := tiledTile.Property(propertyWeight); ok {
+		var err error
+		if tile.Weight, err = strconv.ParseFloat(weight, 64); err != nil || tile.Weight < 0 {
+			return tile, fmt.Errorf("tile %d has an invalid weight %q, expected a number of 0 or more", tiledTile.Id, weight)
+		}
+	}
+
+	if tags, ok := tiledTile.Property(propertyTags); ok {
+		tile.Tags = splitList(tags)
+	}
+
+	if variants, ok := tiledTile.Property(propertyVariants); ok {
+		for _, variant := range strings.Split(variants, ",") {
+			transform := tileset.Transform(strings.TrimSpace(variant))
+			if err := transform.Validate(); err != nil {
+				return tile, fmt.Errorf("tile %d has invalid variants: %v", tiledTile.Id, err)
+			}
+			tile.Variants = append(tile.Variants, transform)
+		}
+	}
+
+	return tile, nil
+}
+
+// Splits a comma separated property, ignoring empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,198 @@
+package tiled
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"wavefunctioncollapse/tileset"
+)
+
+// Bits set on a global tile ID in a map to flip the tile, see https://doc.mapeditor.org/en/stable/reference/global-tile-ids/
+// Tiled flips diagonally first, swapping x and y, then horizontally, then vertically
+const (
+	FlipHorizontal uint32 = 0x80000000
+	FlipVertical   uint32 = 0x40000000
+	FlipDiagonal   uint32 = 0x20000000
+)
+
+// Version of the Tiled file formats written
+const formatVersion = "1.10"
+
+// Names of the tile properties holding what wfc needs that Tiled doesn't have
+const (
+	propertyWeight      = "weight"
+	propertyTags        = "tags"
+	propertySymmetry    = "symmetry"
+	propertyVariants    = "variants"
+	propertyDisplayName = "displayName"
+)
+
+// Tileset is a Tiled .tsx tileset, either one image sliced into tiles or a collection of tile images
+type Tileset struct {
+	XMLName         xml.Name         `xml:"tileset"`
+	Version         string           `xml:"version,attr,omitempty"`
+	Name            string           `xml:"name,attr"`
+	TileWidth       int              `xml:"tilewidth,attr"`
+	TileHeight      int              `xml:"tileheight,attr"`
+	Spacing         int              `xml:"spacing,attr,omitempty"`
+	Margin          int              `xml:"margin,attr,omitempty"`
+	TileCount       int              `xml:"tilecount,attr"`
+	Columns         int              `xml:"columns,attr"`
+	Transformations *Transformations `xml:"transformations"`
+	Image           *Image           `xml:"image"` // set when the tileset is a single image
+	Tiles           []Tile           `xml:"tile"`
+}
+
+// Transformations lists how tiles can be flipped when placed in Tiled
+type Transformations struct {
+	HFlip               int `xml:"hflip,attr"`
+	VFlip               int `xml:"vflip,attr"`
+	Rotate              int `xml:"rotate,attr"`
+	PreferUntransformed int `xml:"preferuntransformed,attr"`
+}
+
+// Image is an image file, relative to the file referencing it
+type Image struct {
+	Source string `xml:"source,attr"`
+	Width  int    `xml:"width,attr,omitempty"`
+	Height int    `xml:"height,attr,omitempty"`
+}
+
+// Tile holds the properties of a tile, and its image in a collection
+// A collection tile's X, Y, Width and Height select part of its image, the whole image if they're 0
+type Tile struct {
+	Id         int        `xml:"id,attr"`
+	X          int        `xml:"x,attr,omitempty"`
+	Y          int        `xml:"y,attr,omitempty"`
+	Width      int        `xml:"width,attr,omitempty"`
+	Height     int        `xml:"height,attr,omitempty"`
+	Properties []Property `xml:"properties>property"`
+	Image      *Image     `xml:"image"`
+}
+
+// Property is a custom property, Tiled assumes a string if Type is empty
+type Property struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:"value,attr"`
+}
+
+// Returns the value of a property, false if the tile doesn't have it
+func (tile Tile) Property(name string) (string, bool) {
+	for _, property := range tile.Properties {
+		if property.Name == name {
+			return property.Value, true
+		}
+	}
+	return "", false
+}
+
+// Map is a Tiled .tmx map with tile layers
+type Map struct {
+	XMLName      xml.Name     `xml:"map"`
+	Version      string       `xml:"version,attr"`
+	Orientation  string       `xml:"orientation,attr"`
+	RenderOrder  string       `xml:"renderorder,attr"`
+	Width        int          `xml:"width,attr"`
+	Height       int          `xml:"height,attr"`
+	TileWidth    int          `xml:"tilewidth,attr"`
+	TileHeight   int          `xml:"tileheight,attr"`
+	Infinite     int          `xml:"infinite,attr"`
+	NextLayerId  int          `xml:"nextlayerid,attr"`
+	NextObjectId int          `xml:"nextobjectid,attr"`
+	Properties   []Property   `xml:"properties>property"`
+	Tilesets     []TilesetRef `xml:"tileset"`
+	Layers       []Layer      `xml:"layer"`
+}
+
+// TilesetRef points a map at an external tileset, its tiles start at FirstGid
+type TilesetRef struct {
+	FirstGid int    `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// Layer is a grid of global tile IDs
+type Layer struct {
+	Id     int    `xml:"id,attr"`
+	Name   string `xml:"name,attr"`
+	Width  int    `xml:"width,attr"`
+	Height int    `xml:"height,attr"`
+	Data   Data   `xml:"data"`
+}
+
+// Data holds a layer's global tile IDs, only CSV encoding is written
+type Data struct {
+	Encoding string `xml:"encoding,attr"`
+	Text     string `xml:",innerxml"` // written as is, CSV needs no escaping and Tiled expects the newlines
+}
+
+// Returns the flip bits drawing a tile's image with the transform applied
+// Every combination of turns and mirrors ends up as one of the eight orientations Tiled can flip a tile into
+func Flags(transform tileset.Transform) uint32 {
+	// Track where the x and y axes of the image end up, as a matrix of -1, 0 and 1 in screen coordinates
+	xx, xy, yx, yy := 1, 0, 0, 1
+	for _, op := range transform {
+		switch op {
+		case 'R':
+			// (x, y) turns clockwise to (-y, x)
+			xx, xy, yx, yy = -yx, -yy, xx, xy
+		case 'H':
+			xx, xy = -xx, -xy
+		case 'V':
+			yx, yy = -yx, -yy
+		case 'F':
+			xx, xy, yx, yy = -xx, -xy, -yx, -yy
+		}
+	}
+
+	flags := uint32(0)
+	if xx == 0 {
+		// Axes are swapped, Tiled swaps them first and then flips the result
+		flags |= FlipDiagonal
+		if xy < 0 {
+			flags |= FlipHorizontal
+		}
+		if yx < 0 {
+			flags |= FlipVertical
+		}
+		return flags
+	}
+
+	if xx < 0 {
+		flags |= FlipHorizontal
+	}
+	if yy < 0 {
+		flags |= FlipVertical
+	}
+	return flags
+}
+
+// Returns the transform for a tile placed in Tiled with the given flip bits, the inverse of Flags
+func FlagsTransform(flags uint32) tileset.Transform {
+	transform := tileset.Transform("")
+	if flags&FlipDiagonal != 0 {
+		// Swapping the axes is a clockwise turn then a left to right mirror
+		transform += "RH"
+	}
+	if flags&FlipHorizontal != 0 {
+		transform += "H"
+	}
+	if flags&FlipVertical != 0 {
+		transform += "V"
+	}
+	return transform
+}
+
+// Writes a Tiled file with the XML header it expects
+func writeXML(filePath string, value interface{}) error {
+	data, err := xml.MarshalIndent(value, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s with error %v", filePath, err)
+	}
+
+	data = append([]byte(xml.Header), append(data, '\n')...)
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s with error %v", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package tiled
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/disintegration/imaging"
+)
+
+func Test_Flags(t *testing.T) {
+	testCases := []struct {
+		transform tileset.Transform
+		expected  uint32
+	}{
+		{"", 0},
+		{"R", FlipDiagonal | FlipHorizontal},
+		{"RR", FlipHorizontal | FlipVertical},
+		{"F", FlipHorizontal | FlipVertical},
+		{"RRR", FlipDiagonal | FlipVertical},
+		{"H", FlipHorizontal},
+		{"V", FlipVertical},
+		{"RH", FlipDiagonal},
+		{"HV", FlipHorizontal | FlipVertical},
+	}
+
+	for _, tc := range testCases {
+		if res := Flags(tc.transform); res != tc.expected {
+			t.Errorf("Failed, %q expected %#x, got %#x", tc.transform, tc.expected, res)
+		}
+	}
+}
+
+func Test_Flags_matchImages(t *testing.T) {
+	// Every pixel a different colour, so any difference in orientation shows
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.NRGBA{0, 255, 0, 255})
+	img.Set(0, 1, color.NRGBA{0, 0, 255, 255})
+	img.Set(1, 1, color.NRGBA{255, 255, 255, 255})
+
+	for _, transform := range []tileset.Transform{"", "R", "RR", "RRR", "H", "V", "F", "RH", "HR", "RV", "VRH"} {
+		// Draw the tile the way Tiled does, diagonal flip first, then horizontal, then vertical
+		flags := Flags(transform)
+		var tiled image.Image = img
+		if flags&FlipDiagonal != 0 {
+			tiled = imaging.Transpose(tiled)
+		}
+		if flags&FlipHorizontal != 0 {
+			tiled = imaging.FlipH(tiled)
+		}
+		if flags&FlipVertical != 0 {
+			tiled = imaging.FlipV(tiled)
+		}
+
+		expected := imaging.Clone(transform.Apply(img))
+		if !reflect.DeepEqual(expected.Pix, imaging.Clone(tiled).Pix) {
+			t.Errorf("Failed, %q with flags %#x expected the same image as the transform", transform, flags)
+		}
+
+		if res := Flags(FlagsTransform(flags)); res != flags {
+			t.Errorf("Failed, %#x expected the same flags back from its transform, got %#x", flags, res)
+		}
+	}
+}
+
+func Test_Export_variants(t *testing.T) {
+	base := tileset.Tile{
+		Name:        "t-junction-up.png",
+		Connections: tileset.Connections{wfc.LEFT: "AAB", wfc.UP: "ABC", wfc.RIGHT: "CAA", wfc.DOWN: "AAA"},
+		Weight:      2,
+		Tags:        []string{"road"},
+	}
+	rotated, err := base.Transformed("R")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	ts := tileset.New("../assets", []tileset.Tile{rotated, {Name: "blank.png"}, base})
+
+	export, err := NewExport(ts, "../assets/exported")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	expectedGids := []uint32{FlipDiagonal | FlipHorizontal, 1, 0}
+	if !reflect.DeepEqual(expectedGids, export.Gids) {
+		t.Errorf("Failed, expected gids %v, got %v", expectedGids, export.Gids)
+	}
+
+	if len(export.Tileset.Tiles) != 2 || export.Tileset.Tiles[0].Image.Source != "../t-junction-up.png" {
+		t.Fatalf("Failed, expected two tiles with images relative to the output, got %+v", export.Tileset.Tiles)
+	}
+
+	// The untransformed tile describes the image, whichever comes first
+	tiledTile := export.Tileset.Tiles[0]
+	for dir, connector := range base.Connections {
+		if value, _ := tiledTile.Property(tileset.DirectionNames[dir]); value != connector {
+			t.Errorf("Failed, %v expected %v, got %v", tileset.DirectionNames[dir], connector, value)
+		}
+	}
+
+	tiledMap, err := export.Map([][]int{{0, wfc.Undecided}, {1, 2}}, "exported.tsx")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	expected := "\n2684354561,2,\n0,1\n"
+	if tiledMap.Layers[0].Data.Text != expected {
+		t.Errorf("Failed, expected layer %q, got %q", expected, tiledMap.Layers[0].Data.Text)
+	}
+}
+
+func Test_Import_roundTrip(t *testing.T) {
+	ts, err := tileset.Load("../assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	ts.Tiles[0].Weight = 0.5
+	ts.Tiles[1].Tags = []string{"road", "junction"}
+	ts.Tiles[2].Variants = []tileset.Transform{"", "H"}
+
+	dir := t.TempDir()
+	tsxPath := filepath.Join(dir, "tiles", "assets.tsx")
+	if err := os.Mkdir(filepath.Dir(tsxPath), 0755); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	if _, err := WriteTileset(ts, tsxPath); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	res, err := Import(tsxPath, "../assets")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(ts.Tiles, res.Tiles) {
+		t.Errorf("Failed, expected %+v, got %+v", ts.Tiles, res.Tiles)
+	}
+}
+
+func Test_Import_atlas(t *testing.T) {
+	dir := t.TempDir()
+	if err := imaging.Save(image.NewNRGBA(image.Rect(0, 0, 8, 4)), filepath.Join(dir, "atlas.png")); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		tsx      string
+		expected []string
+		left     []string // left connector of each tile
+	}{
+		{
+			"Single image",
+			`<tileset name="a" tilewidth="4" tileheight="4" tilecount="2" columns="2"><image source="atlas.png" width="8" height="4"/>
+			<tile id="1"><properties><property name="left" value="AAA"/></properties></tile></tileset>`,
+			[]string{"atlas.png#0", "atlas.png#1"},
+			[]string{"", "AAA"},
+		},
+		{
+			"Single image without a tile count",
+			`<tileset name="a" tilewidth="4" tileheight="4"><image source="atlas.png" width="8" height="4"/></tileset>`,
+			[]string{"atlas.png#0", "atlas.png#1"},
+			[]string{"", ""},
+		},
+		{
+			"Collection of image areas",
+			`<tileset name="a" tilewidth="4" tileheight="4" tilecount="2" columns="0">
+			<tile id="0" x="4" width="4" height="4"><image source="atlas.png" width="8" height="4"/></tile>
+			<tile id="1" x="0" width="4" height="4"><image source="atlas.png" width="8" height="4"/></tile></tileset>`,
+			[]string{"atlas.png#1", "atlas.png#0"},
+			[]string{"", ""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tsxPath := filepath.Join(dir, "tiles.tsx")
+			if err := os.WriteFile(tsxPath, []byte(tc.tsx), 0644); err != nil {
+				t.Fatalf("Failed, expected no error, got %v", err)
+			}
+
+			res, err := Import(tsxPath, dir)
+			if err != nil {
+				t.Fatalf("Failed, expected no error, got %v", err)
+			}
+
+			names := make([]string, len(res.Tiles))
+			left := make([]string, len(res.Tiles))
+			for idx, tile := range res.Tiles {
+				names[idx] = tile.Name
+				left[idx] = tile.Connections[wfc.LEFT]
+				if _, err := res.Loader().BaseImage(tile.Name); err != nil {
+					t.Errorf("Failed, expected %s to load, got %v", tile.Name, err)
+				}
+			}
+			if !reflect.DeepEqual(tc.expected, names) || len(res.Settings.Atlases) != 1 {
+				t.Errorf("Failed, expected tiles %v from one atlas, got %v from %v", tc.expected, names, res.Settings.Atlases)
+			}
+			if !reflect.DeepEqual(tc.left, left) {
+				t.Errorf("Failed, expected left connectors %q, got %q", tc.left, left)
+			}
+		})
+	}
+}
+
+func Test_Import_unevenArea(t *testing.T) {
+	tsxPath := filepath.Join(t.TempDir(), "tiles.tsx")
+	tsx := `<tileset name="a" tilewidth="4" tileheight="4" tilecount="1" columns="0">
+		<tile id="0" x="3" width="4" height="4"><image source="atlas.png" width="8" height="4"/></tile></tileset>`
+	if err := os.WriteFile(tsxPath, []byte(tsx), 0644); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if _, err := Import(tsxPath, "."); err == nil || !strings.Contains(err.Error(), "evenly sliced") {
+		t.Errorf("Failed, expected an error for an area off the grid, got %v", err)
+	}
+}
+
+func Test_Import_tileCountLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tsx      string
+		expected string
+	}{
+		{
+			"More tiles than the image holds",
+			`<tileset name="a" tilewidth="4" tileheight="4" tilecount="3" columns="1000000"><image source="atlas.png" width="8" height="4"/></tileset>`,
+			"more than the 2 tiles its image holds",
+		},
+		{
+			"Huge tile count without an image size",
+			`<tileset name="a" tilewidth="4" tileheight="4" tilecount="2000000000"><image source="atlas.png"/></tileset>`,
+			"that can be imported",
+		},
+		{
+			"Huge image",
+			`<tileset name="a" tilewidth="1" tileheight="1"><image source="atlas.png" width="100000" height="100000"/></tileset>`,
+			"that can be imported",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tsxPath := filepath.Join(t.TempDir(), "tiles.tsx")
+			if err := os.WriteFile(tsxPath, []byte(tc.tsx), 0644); err != nil {
+				t.Fatalf("Failed, expected no error, got %v", err)
+			}
+
+			if _, err := Import(tsxPath, "."); err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Failed, expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
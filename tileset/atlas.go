@@ -0,0 +1,239 @@
+package tileset
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// File describing the atlases in a version 1 tileset directory, newer versions keep them in the settings
+const atlasConfig = "atlas.json"
+
+// Atlas describes how a single image is sliced into tiles
+// Tiles in an atlas are named <image>#<index> or <image>#<name>, indexes count left to right then top to bottom
+type Atlas struct {
+	Image      string         `json:"image" yaml:"image" toml:"image"`
+	TileWidth  int            `json:"tileWidth" yaml:"tileWidth" toml:"tileWidth"`
+	TileHeight int            `json:"tileHeight" yaml:"tileHeight" toml:"tileHeight"`
+	Margin     int            `json:"margin,omitempty" yaml:"margin,omitempty" toml:"margin,omitzero"`    // pixels around the edge of the image before the first tile
+	Spacing    int            `json:"spacing,omitempty" yaml:"spacing,omitempty" toml:"spacing,omitzero"` // pixels between neighbouring tiles
+	Names      map[string]int `json:"names,omitempty" yaml:"names,omitempty" toml:"names,omitempty"`      // optional names for tile indexes
+}
+
+// Returns the number of tile columns and rows in an image of the given size
+func (atlas Atlas) Grid(bounds image.Rectangle) (int, int) {
+	columns := (bounds.Dx() - 2*atlas.Margin + atlas.Spacing) / (atlas.TileWidth + atlas.Spacing)
+	rows := (bounds.Dy() - 2*atlas.Margin + atlas.Spacing) / (atlas.TileHeight + atlas.Spacing)
+	return columns, rows
+}
+
+// Returns the area of the tile at index in an image of the given size
+func (atlas Atlas) TileRect(bounds image.Rectangle, index int) (image.Rectangle, error) {
+	columns, rows := atlas.Grid(bounds)
+	if index < 0 || index >= columns*rows {
+		return image.Rectangle{}, fmt.Errorf("tile %d out of range, atlas %s has %d tiles", index, atlas.Image, columns*rows)
+	}
+
+	x := bounds.Min.X + atlas.Margin + (index%columns)*(atlas.TileWidth+atlas.Spacing)
+	y := bounds.Min.Y + atlas.Margin + (index/columns)*(atlas.TileHeight+atlas.Spacing)
+	return image.Rect(x, y, x+atlas.TileWidth, y+atlas.TileHeight), nil
+}
+
+// Returns the index of a tile in the atlas from its index or name
+func (atlas Atlas) index(ref string) (int, error) {
+	if index, ok := atlas.Names[ref]; ok {
+		return index, nil
+	}
+
+	index, err := strconv.Atoi(ref)
+	if err != nil {
+		return 0, fmt.Errorf("atlas %s has no tile named %q", atlas.Image, ref)
+	}
+	return index, nil
+}
+
+// Checks the atlas can be sliced
+func (atlas Atlas) validate() error {
+	if atlas.TileWidth <= 0 || atlas.TileHeight <= 0 || atlas.Margin < 0 || atlas.Spacing < 0 {
+		return fmt.Errorf("atlas %s needs a positive tile size and no negative margin or spacing", atlas.Image)
+	}
+	return nil
+}
+
+// Returns the names of every tile in the atlas, using the atlas names where a tile has one
+func (atlas Atlas) TileNames(bounds image.Rectangle) []string {
+	byIndex := make(map[int]string, len(atlas.Names))
+	for name, index := range atlas.Names {
+		byIndex[index] = name
+	}
+
+	columns, rows := atlas.Grid(bounds)
+	names := make([]string, 0, columns*rows)
+	for index := 0; index < columns*rows; index++ {
+		ref, ok := byIndex[index]
+		if !ok {
+			ref = strconv.Itoa(index)
+		}
+		names = append(names, atlas.Image+"#"+ref)
+	}
+
+	return names
+}
+
+// Reads the atlases from a version 1 tileset's atlas.json, nil if there isn't one
+func LoadAtlases(dirPath string) ([]Atlas, error) {
+	atlasPath := path.Join(dirPath, atlasConfig)
+	data, err := os.ReadFile(atlasPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s with err %v", atlasPath, err)
+	}
+
+	var atlases []Atlas
+	if err := json.Unmarshal(data, &atlases); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s with err %v", atlasPath, err)
+	}
+
+	for _, atlas := range atlases {
+		if err := atlas.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", atlasPath, err)
+		}
+	}
+
+	return atlases, nil
+}
+
+// TileLoader opens tile images from a directory, slicing atlases and caching images shared between tiles
+type TileLoader struct {
+	dirPath string
+	atlases map[string]Atlas
+	images  map[string]image.Image
+}
+
+// Returns a loader for tile images in dirPath, slicing the given atlases
+func NewTileLoader(dirPath string, atlases []Atlas) *TileLoader {
+	byImage := make(map[string]Atlas, len(atlases))
+	for _, atlas := range atlases {
+		byImage[atlas.Image] = atlas
+	}
+
+	return &TileLoader{
+		dirPath: dirPath,
+		atlases: byImage,
+		images:  make(map[string]image.Image),
+	}
+}
+
+// Returns the image a tile name refers to without applying any transform
+// Names are either an image file, or an atlas image and tile separated by #
+func (loader *TileLoader) BaseImage(name string) (image.Image, error) {
+	if img, ok := loader.images[name]; ok {
+		return img, nil
+	}
+
+	file, rect, err := loader.Source(name)
+	if err != nil {
+		return nil, err
+	}
+
+	img := loader.images[file]
+	if rect != img.Bounds() {
+		img = imaging.Crop(img, rect)
+		loader.images[name] = img
+	}
+
+	return img, nil
+}
+
+// Returns the image file a tile name refers to and the area of the tile within it
+// The area is the whole image unless the tile is in an atlas
+func (loader *TileLoader) Source(name string) (string, image.Rectangle, error) {
+	file, ref, isAtlasTile := strings.Cut(name, "#")
+	img, err := loader.fileImage(file)
+	if err != nil {
+		return "", image.Rectangle{}, err
+	}
+
+	if !isAtlasTile {
+		return file, img.Bounds(), nil
+	}
+
+	atlas, ok := loader.atlases[file]
+	if !ok {
+		return "", image.Rectangle{}, fmt.Errorf("tile %s refers to %s, which isn't defined as an atlas", name, file)
+	}
+
+	index, err := atlas.index(ref)
+	if err != nil {
+		return "", image.Rectangle{}, err
+	}
+
+	rect, err := atlas.TileRect(img.Bounds(), index)
+	if err != nil {
+		return "", image.Rectangle{}, err
+	}
+	return file, rect, nil
+}
+
+// Returns the size of an image file, e.g. an atlas returned by Source
+func (loader *TileLoader) ImageSize(file string) (image.Point, error) {
+	img, err := loader.fileImage(file)
+	if err != nil {
+		return image.Point{}, err
+	}
+	return img.Bounds().Size(), nil
+}
+
+// Returns a whole image file, opening it the first time it's needed
+func (loader *TileLoader) fileImage(file string) (image.Image, error) {
+	if img, ok := loader.images[file]; ok {
+		return img, nil
+	}
+
+	img, err := OpenImage(JoinPath(loader.dirPath, file))
+	if err != nil {
+		return nil, err
+	}
+	loader.images[file] = img
+	return img, nil
+}
+
+// Returns a tile's image with its transform applied
+func (loader *TileLoader) Image(tile Tile) (image.Image, error) {
+	img, err := loader.BaseImage(tile.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return tile.Transform.Apply(img), nil
+}
+
+// Opens and decodes an image file
+func OpenImage(imgPath string) (image.Image, error) {
+	imgReader, err := os.Open(imgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image %s with error %v", imgPath, err)
+	}
+	defer imgReader.Close()
+
+	img, _, err := image.Decode(imgReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s with error %v", imgPath, err)
+	}
+
+	return img, nil
+}
+
+// Joins a tile name onto its directory, names are always slash separated
+func JoinPath(dirPath, name string) string {
+	return filepath.Join(dirPath, filepath.FromSlash(name))
+}
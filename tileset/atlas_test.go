@@ -0,0 +1,36 @@
+package tileset
+
+import (
+	"image"
+	"testing"
+)
+
+func Test_Atlas_TileRect(t *testing.T) {
+	// 3x2 tiles of 4 pixels, with a 1 pixel margin and 2 pixels between tiles
+	atlas := Atlas{Image: "atlas.png", TileWidth: 4, TileHeight: 4, Margin: 1, Spacing: 2, Names: map[string]int{"water": 4}}
+	bounds := image.Rect(0, 0, 1+4+2+4+2+4+1, 1+4+2+4+1)
+
+	columns, rows := atlas.Grid(bounds)
+	if columns != 3 || rows != 2 {
+		t.Fatalf("Failed, expected %vx%v tiles, got %vx%v", 3, 2, columns, rows)
+	}
+
+	index, err := atlas.index("water")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	rect, err := atlas.TileRect(bounds, index)
+	expected := image.Rect(7, 7, 11, 11)
+	if err != nil || rect != expected {
+		t.Errorf("Failed, expected %v, got %v with err %v", expected, rect, err)
+	}
+
+	if _, err := atlas.TileRect(bounds, 6); err == nil {
+		t.Errorf("Failed, expected error for tile out of range")
+	}
+
+	if _, err := atlas.index("lava"); err == nil {
+		t.Errorf("Failed, expected error for unknown tile name")
+	}
+}
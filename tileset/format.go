@@ -0,0 +1,229 @@
+package tileset
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the file format of a tileset config, detected from its extension
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// Config file names checked in a tileset directory, a directory can only have one of them
+var configFiles = []struct {
+	name   string
+	format Format
+}{
+	{"config.json", JSON},
+	{"config.yaml", YAML},
+	{"config.yml", YAML},
+	{"config.toml", TOML},
+}
+
+// Returns the format for a config file name from its extension
+func FormatOf(name string) (Format, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	}
+	return "", fmt.Errorf("unknown config format %s, expected .json, .yaml, .yml or .toml", name)
+}
+
+// Returns the name of the config file written for the format
+func (format Format) FileName() string {
+	if format == "" {
+		format = JSON
+	}
+	return "config." + string(format)
+}
+
+// Returns the path and format of the config in dir
+// The error wraps os.ErrNotExist if there's no config, and it's an error to have more than one
+func FindConfig(dir string) (string, Format, error) {
+	found := make([]string, 0, 1)
+	format := Format("")
+	for _, file := range configFiles {
+		configPath := path.Join(dir, file.name)
+		if _, err := os.Stat(configPath); err == nil {
+			found = append(found, configPath)
+			format = file.format
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", "", fmt.Errorf("no config.json, config.yaml or config.toml in %s: %w", dir, os.ErrNotExist)
+	case 1:
+		return found[0], format, nil
+	}
+	return "", "", fmt.Errorf("%s has more than one config, remove all but one of %s", dir, strings.Join(found, ", "))
+}
+
+// Decodes a config in the given format into ts, errors point at the line at fault where the decoder knows it
+func decode(data []byte, format Format, ts *Tileset) error {
+	switch format {
+	case JSON, "":
+		if err := json.Unmarshal(data, ts); err != nil {
+			return directionLine(data, `"%s"\s*:`, jsonError(data, err))
+		}
+	case YAML:
+		if err := yaml.Unmarshal(data, ts); err != nil {
+			var typeErr *yaml.TypeError
+			if errors.As(err, &typeErr) {
+				return errors.New(strings.Join(typeErr.Errors, ", "))
+			}
+			return errors.New(strings.TrimPrefix(err.Error(), "yaml: "))
+		}
+	case TOML:
+		if _, err := toml.Decode(string(data), ts); err != nil {
+			err = directionLine(data, `(?m)(^|[\s{,])"?%s"?\s*=`, err)
+			return errors.New(strings.TrimPrefix(err.Error(), "toml: "))
+		}
+	default:
+		return fmt.Errorf("unknown config format %q", format)
+	}
+
+	return nil
+}
+
+// Encodes ts in the given format
+func encode(ts *Tileset, format Format) ([]byte, error) {
+	buf := bytes.Buffer{}
+	switch format {
+	case JSON, "":
+		data, err := json.MarshalIndent(ts, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	case YAML:
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(ts); err != nil {
+			return nil, err
+		}
+	case TOML:
+		encoder := toml.NewEncoder(&buf)
+		encoder.Indent = ""
+		if err := encoder.Encode(ts); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Adds the line to JSON syntax and type errors, which only report a byte offset
+func jsonError(data []byte, err error) error {
+	offset := int64(-1)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		offset = typeErr.Offset
+	}
+
+	if offset < 0 || offset > int64(len(data)) {
+		return err
+	}
+	return fmt.Errorf("line %d: %v", 1+bytes.Count(data[:offset], []byte("\n")), err)
+}
+
+// Adds the line of the first key matching pattern to an unknown direction error
+// The JSON and TOML decoders don't report where an unmarshaler failed, so the key is found in the config instead
+func directionLine(data []byte, pattern string, err error) error {
+	var dirErr directionError
+	if !errors.As(err, &dirErr) {
+		return err
+	}
+
+	loc := regexp.MustCompile(fmt.Sprintf(pattern, regexp.QuoteMeta(dirErr.key))).FindIndex(data)
+	if loc == nil {
+		return err
+	}
+	return fmt.Errorf("line %d: %v", 1+bytes.Count(data[:loc[0]], []byte("\n")), dirErr)
+}
+
+// Writes connections as a mapping with named directions, in direction order
+func (connections Connections) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, dir := range connections.directions() {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: DirectionNames[dir]},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: connections[dir], Style: yaml.DoubleQuotedStyle})
+	}
+	return node, nil
+}
+
+func (connections *Connections) UnmarshalYAML(node *yaml.Node) error {
+	var named map[string]string
+	if err := node.Decode(&named); err != nil {
+		return err
+	}
+
+	parsed, err := parseConnections(named)
+	if err != nil {
+		return fmt.Errorf("line %d: %v", node.Line, err)
+	}
+	*connections = parsed
+	return nil
+}
+
+// Writes connections as an inline table with named directions, in direction order
+func (connections Connections) MarshalTOML() ([]byte, error) {
+	pairs := make([]string, 0, len(connections))
+	for _, dir := range connections.directions() {
+		// JSON string escapes are valid in TOML basic strings
+		value, err := json.Marshal(connections[dir])
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, DirectionNames[dir]+" = "+string(value))
+	}
+	return []byte("{ " + strings.Join(pairs, ", ") + " }"), nil
+}
+
+func (connections *Connections) UnmarshalTOML(data interface{}) error {
+	table, ok := data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("connections must be a table of directions, got %v", data)
+	}
+
+	named := make(map[string]string, len(table))
+	for key, value := range table {
+		connector, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s connector must be a string, got %v", key, value)
+		}
+		named[key] = connector
+	}
+
+	parsed, err := parseConnections(named)
+	if err != nil {
+		return err
+	}
+	*connections = parsed
+	return nil
+}
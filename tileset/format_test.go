@@ -0,0 +1,91 @@
+package tileset
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_formats_roundTrip(t *testing.T) {
+	ts, err := Load("../assets/circuit")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	ts.Settings = Settings{Name: "circuit", Width: 10, Atlases: []Atlas{{Image: "atlas.png", TileWidth: 4, TileHeight: 4}}}
+	ts.Tiles[0].Weight = 2.5
+	ts.Tiles[0].Tags = []string{"board"}
+
+	for _, format := range []Format{JSON, YAML, TOML} {
+		data, err := encode(ts, format)
+		if err != nil {
+			t.Fatalf("Failed, %v expected no error, got %v", format, err)
+		}
+
+		res, err := Parse(data, format, ts.Dir())
+		if err != nil {
+			t.Fatalf("Failed, %v expected no error, got %v", format, err)
+		}
+
+		if !reflect.DeepEqual(ts.Tiles, res.Tiles) || !reflect.DeepEqual(ts.Settings, res.Settings) {
+			t.Errorf("Failed, %v expected the same tileset after a round trip, got %+v", format, res)
+		}
+	}
+}
+
+func Test_formats_errorLines(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format Format
+		config string
+		line   string
+	}{
+		{"JSON syntax", JSON, "{\n\"version\": 2,\n\"tiles\": [\n{\"name\": \"a\",, }]}", "line 4"},
+		{"JSON type", JSON, "{\n\"version\": 2,\n\"tiles\": [\n{\"name\": \"a\", \"weight\": \"x\"}]}", "line 4"},
+		{"JSON direction", JSON, "{\n\"version\": 2,\n\"tiles\": [\n{\"name\": \"a\", \"connections\": {\"north\": \"A\"}}]}", "line 4"},
+		{"YAML type", YAML, "version: 2\ntiles:\n  - name: a\n    weight: x\n", "line 4"},
+		{"YAML direction", YAML, "version: 2\ntiles:\n  - name: a\n    connections:\n      north: A\n", "line 5"},
+		{"TOML syntax", TOML, "version = 2\n[[tiles]]\nname = = \"a\"\n", "line 3"},
+		{"TOML type", TOML, "version = 2\n[[tiles]]\nname = \"a\"\nweight = \"x\"\n", "line 4"},
+		{"TOML direction", TOML, "version = 2\n[[tiles]]\nname = \"a\"\nconnections = { north = \"A\" }\n", "line 4"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.config), tc.format, ".")
+			if err == nil || !strings.HasPrefix(err.Error(), tc.line) {
+				t.Errorf("Failed, expected error starting %q, got %v", tc.line, err)
+			}
+		})
+	}
+}
+
+func Test_Load_formats(t *testing.T) {
+	dir := t.TempDir()
+	yamlConfig := "# designers can leave comments\nversion: 2\ntiles:\n  - name: a.png\n    connections: {left: A, up: A, right: A, down: A}\n"
+	if err := os.WriteFile(path.Join(dir, "config.yml"), []byte(yamlConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := Load(dir)
+	if err != nil || ts.Format() != YAML || len(ts.Tiles) != 1 {
+		t.Fatalf("Failed, expected a YAML tileset with 1 tile, got %+v with err %v", ts, err)
+	}
+
+	if err := os.WriteFile(path.Join(dir, "config.toml"), []byte("version = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(dir); err == nil {
+		t.Errorf("Failed, expected error for a directory with two configs")
+	}
+
+	// Saving replaces every other config, keeping the format the tileset was read in
+	if err := ts.Save(dir); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	configPath, format, err := FindConfig(dir)
+	if err != nil || format != YAML || path.Base(configPath) != "config.yaml" {
+		t.Errorf("Failed, expected a single config.yaml, got %v %v with err %v", configPath, format, err)
+	}
+}
@@ -0,0 +1,359 @@
+package tileset
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"wavefunctioncollapse/wfc"
+)
+
+// Schema version written by Save, older versions are migrated when loaded
+const CurrentVersion = 2
+
+// Names of the directions used as connection keys in the config
+var DirectionNames = map[int]string{wfc.LEFT: "left", wfc.UP: "up", wfc.RIGHT: "right", wfc.DOWN: "down"}
+
+// Tileset is a set of tiles and the settings to generate with them, shared by the gui, renderer and processors
+type Tileset struct {
+	Version  int      `json:"version" yaml:"version" toml:"version"`
+	Settings Settings `json:"settings" yaml:"settings" toml:"settings"`
+	Tiles    []Tile   `json:"tiles" yaml:"tiles" toml:"tiles"`
+	dir      string   // directory the tileset was loaded from, images are relative to it
+	format   Format   // format the config was read in, and is saved in
+}
+
+// Settings apply to the whole tileset
+type Settings struct {
+	Name     string  `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+	Width    int     `json:"width,omitempty" yaml:"width,omitempty" toml:"width,omitzero"`          // default grid width to generate
+	Height   int     `json:"height,omitempty" yaml:"height,omitempty" toml:"height,omitzero"`       // default grid height to generate
+	TileSize int     `json:"tileSize,omitempty" yaml:"tileSize,omitempty" toml:"tileSize,omitzero"` // default pixel size of a tile when rendering to an image
+	Atlases  []Atlas `json:"atlases,omitempty" yaml:"atlases,omitempty" toml:"atlases,omitempty"`   // images sliced into tiles
+}
+
+// Tile is a single tile in the tileset, its ID is its position in the tileset
+type Tile struct {
+	Name        string      `json:"name" yaml:"name" toml:"name"`                                                    // image, or atlas image and tile separated by #
+	DisplayName string      `json:"displayName,omitempty" yaml:"displayName,omitempty" toml:"displayName,omitempty"` // shown instead of the name in tools
+	Connections Connections `json:"connections" yaml:"connections" toml:"connections"`
+	Weight      float64     `json:"weight,omitempty" yaml:"weight,omitempty" toml:"weight,omitzero"`           // how often the tile is picked relative to others, defaults to 1
+	Tags        []string    `json:"tags,omitempty" yaml:"tags,omitempty" toml:"tags,omitempty"`                // groups of tiles, e.g. water, used to constrain generation
+	Symmetry    string      `json:"symmetry,omitempty" yaml:"symmetry,omitempty" toml:"symmetry,omitempty"`    // symmetry class used when processing, detected from pixels if empty
+	Variants    []Transform `json:"variants,omitempty" yaml:"variants,omitempty" toml:"variants,omitempty"`    // exact transforms to produce when processing, overrides Symmetry
+	Transform   Transform   `json:"transform,omitempty" yaml:"transform,omitempty" toml:"transform,omitempty"` // applied to the image named by Name when the tile is drawn
+}
+
+// Returns the name to show for a tile, variants of the same image are told apart by their transform
+func (tile Tile) Label() string {
+	if tile.DisplayName != "" {
+		return tile.DisplayName
+	}
+	if tile.Transform == "" {
+		return tile.Name
+	}
+	return tile.Name + " (" + string(tile.Transform) + ")"
+}
+
+// Connections are a tile's connectors keyed by wfc direction, written to the config with named directions
+type Connections map[int]string
+
+func (connections Connections) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for _, dir := range connections.directions() {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		value, err := json.Marshal(connections[dir])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%q:%s", DirectionNames[dir], value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// Accepts named directions, or the direction numbers used by version 1 configs
+func (connections *Connections) UnmarshalJSON(data []byte) error {
+	var named map[string]string
+	if err := json.Unmarshal(data, &named); err != nil {
+		return err
+	}
+
+	parsed, err := parseConnections(named)
+	if err != nil {
+		return err
+	}
+	*connections = parsed
+	return nil
+}
+
+// Converts connections keyed by direction name or number, shared by every config format
+func parseConnections(named map[string]string) (Connections, error) {
+	connections := make(Connections, len(named))
+	for key, connector := range named {
+		dir, err := ParseDirection(key)
+		if err != nil {
+			return nil, err
+		}
+		connections[dir] = connector
+	}
+
+	return connections, nil
+}
+
+// Returns the directions with a connector, in direction order
+func (connections Connections) directions() []int {
+	dirs := make([]int, 0, len(connections))
+	for dir := wfc.LEFT; dir <= wfc.DOWN; dir++ {
+		if _, ok := connections[dir]; ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Returns the wfc direction for a direction name or number
+func ParseDirection(key string) (int, error) {
+	for dir, name := range DirectionNames {
+		if key == name {
+			return dir, nil
+		}
+	}
+
+	dir, err := strconv.Atoi(key)
+	if err != nil || dir < wfc.LEFT || dir > wfc.DOWN {
+		return 0, directionError{key}
+	}
+	return dir, nil
+}
+
+// directionError is returned for a connection keyed by an unknown direction
+type directionError struct {
+	key string
+}
+
+func (err directionError) Error() string {
+	return fmt.Sprintf("unknown direction %q, expected left, up, right or down", err.key)
+}
+
+// Reads the tileset in dir, from whichever of config.json, config.yaml or config.toml it has
+// Older versions of the config are migrated in memory
+func Load(dir string) (*Tileset, error) {
+	configPath, format, err := FindConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s with err %v", configPath, err)
+	}
+
+	ts, err := Parse(data, format, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", configPath, err)
+	}
+
+	return ts, nil
+}
+
+// Parses a config of any version in the given format, images are relative to dir
+func Parse(data []byte, format Format, dir string) (*Tileset, error) {
+	var ts *Tileset
+	if isV1(data, format) {
+		var err error
+		ts, err = parseV1(data, dir)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ts = &Tileset{}
+		if err := decode(data, format, ts); err != nil {
+			return nil, err
+		}
+	}
+
+	if ts.Version == 0 {
+		return nil, fmt.Errorf("no version set, add version %d to the top of the config", CurrentVersion)
+	}
+	if ts.Version != CurrentVersion {
+		return nil, fmt.Errorf("unsupported config version %d, this build reads versions 1 to %d", ts.Version, CurrentVersion)
+	}
+
+	ts.dir, ts.format = dir, format
+	return ts, ts.validate()
+}
+
+// Reports if the config is version 1, a bare JSON list of tiles
+func isV1(data []byte, format Format) bool {
+	trimmed := bytes.TrimSpace(data)
+	return (format == JSON || format == "") && len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// Reads a version 1 config, a list of tiles with numbered directions and atlases in a separate atlas.json
+func parseV1(data []byte, dir string) (*Tileset, error) {
+	ts := &Tileset{Version: CurrentVersion}
+	if err := json.Unmarshal(data, &ts.Tiles); err != nil {
+		return nil, jsonError(data, err)
+	}
+
+	atlases, err := LoadAtlases(dir)
+	if err != nil {
+		return nil, err
+	}
+	ts.Settings.Atlases = atlases
+
+	return ts, nil
+}
+
+// Checks the values in the config that can't be checked by unmarshalling
+func (ts *Tileset) validate() error {
+	for _, tile := range ts.Tiles {
+		for _, transform := range append([]Transform{tile.Transform}, tile.Variants...) {
+			if err := transform.Validate(); err != nil {
+				return fmt.Errorf("tile %s: %v", tile.Name, err)
+			}
+		}
+
+		if tile.Weight < 0 {
+			return fmt.Errorf("tile %s: weight %g can't be negative", tile.Name, tile.Weight)
+		}
+	}
+
+	for _, atlas := range ts.Settings.Atlases {
+		if err := atlas.validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns a new tileset with the current version, images are relative to dir
+func New(dir string, tiles []Tile) *Tileset {
+	return &Tileset{Version: CurrentVersion, Tiles: tiles, dir: dir, format: JSON}
+}
+
+// Returns the directory images are loaded from
+func (ts *Tileset) Dir() string {
+	return ts.dir
+}
+
+// Returns the format the config was read in, and is saved in
+func (ts *Tileset) Format() Format {
+	return ts.format
+}
+
+// Sets the format the config is saved in
+func (ts *Tileset) SetFormat(format Format) {
+	ts.format = format
+}
+
+// Writes the tileset to the config in dir using the current version, in the format it was read in
+// Any config in dir in another format is removed, so the directory never has two
+func (ts *Tileset) Save(dir string) error {
+	ts.Version = CurrentVersion
+	data, err := encode(ts, ts.format)
+	if err != nil {
+		return err
+	}
+
+	configName := ts.format.FileName()
+	for _, file := range configFiles {
+		if file.name != configName {
+			if err := os.Remove(path.Join(dir, file.name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return os.WriteFile(path.Join(dir, configName), data, 0644)
+}
+
+// Rewrites the config in dir to the current version, keeping the original as config.v<version>.json
+// Returns false if the config was already the current version
+func Migrate(dir string) (bool, error) {
+	configPath, format, err := FindConfig(dir)
+	if err != nil {
+		return false, err
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read file %s with err %v", configPath, err)
+	}
+
+	// Only JSON configs predate versioning
+	version := CurrentVersion
+	if isV1(data, format) {
+		version = 1
+	}
+
+	ts, err := Parse(data, format, dir)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", configPath, err)
+	}
+
+	if version == CurrentVersion {
+		return false, nil
+	}
+
+	backupPath := path.Join(dir, fmt.Sprintf("config.v%d.json", version))
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return false, err
+	}
+
+	return true, ts.Save(dir)
+}
+
+// Returns the tiles for wfc to collapse, each tile's ID is its position in the tileset
+func (ts *Tileset) WfcTiles() []wfc.Tile {
+	tiles := make([]wfc.Tile, len(ts.Tiles))
+	for id, tile := range ts.Tiles {
+		tiles[id] = wfc.Tile{Id: id, Configuration: tile.Connections, Weight: tile.Weight}
+	}
+	return tiles
+}
+
+// Returns the IDs of the tiles with a tag, sorted
+func (ts *Tileset) TaggedIds(tag string) []int {
+	ids := make([]int, 0)
+	for id, tile := range ts.Tiles {
+		for _, tileTag := range tile.Tags {
+			if tileTag == tag {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Returns every tag used in the tileset, sorted
+func (ts *Tileset) Tags() []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0)
+	for _, tile := range ts.Tiles {
+		for _, tag := range tile.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Returns a loader for the tileset's images
+func (ts *Tileset) Loader() *TileLoader {
+	return NewTileLoader(ts.dir, ts.Settings.Atlases)
+}
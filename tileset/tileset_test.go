@@ -0,0 +1,100 @@
+package tileset
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"wavefunctioncollapse/wfc"
+)
+
+func Test_Parse_v1(t *testing.T) {
+	dir := t.TempDir()
+	atlases := `[{"image": "atlas.png", "tileWidth": 4, "tileHeight": 4}]`
+	if err := os.WriteFile(path.Join(dir, atlasConfig), []byte(atlases), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v1 := `[{"name": "atlas.png#0", "connections": {"0": "AAA", "1": "BBB", "2": "CCC", "3": "DDD"}, "transform": "R"}]`
+	ts, err := Parse([]byte(v1), JSON, dir)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if ts.Version != CurrentVersion || len(ts.Tiles) != 1 || len(ts.Settings.Atlases) != 1 {
+		t.Fatalf("Failed, expected version %v with 1 tile and 1 atlas, got %+v", CurrentVersion, ts)
+	}
+
+	tile := ts.Tiles[0]
+	if tile.Connections[wfc.UP] != "BBB" || tile.Transform != "R" || tile.Label() != "atlas.png#0 (R)" {
+		t.Errorf("Failed, expected tile with up connector BBB and transform R, got %+v", tile)
+	}
+}
+
+func Test_Parse_v2(t *testing.T) {
+	v2 := `{
+		"version": 2,
+		"settings": {"name": "roads", "width": 10},
+		"tiles": [
+			{"name": "a.png", "displayName": "Road", "connections": {"left": "A", "up": "B", "right": "C", "down": "D"}, "weight": 2.5, "tags": ["road"]},
+			{"name": "b.png", "connections": {"left": "A", "up": "A", "right": "A", "down": "A"}, "tags": ["grass", "road"]}
+		]
+	}`
+	ts, err := Parse([]byte(v2), JSON, ".")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	tiles := ts.WfcTiles()
+	if tiles[0].Configuration[wfc.RIGHT] != "C" || tiles[0].Weight != 2.5 || tiles[1].Id != 1 {
+		t.Errorf("Failed, expected tiles converted with connectors and weights, got %+v", tiles)
+	}
+
+	if ts.Tiles[0].Label() != "Road" || ts.Settings.Name != "roads" || ts.Settings.Width != 10 {
+		t.Errorf("Failed, expected display name and settings, got %+v", ts)
+	}
+
+	if ids := ts.TaggedIds("road"); len(ids) != 2 {
+		t.Errorf("Failed, expected 2 tiles tagged road, got %v", ids)
+	}
+
+	invalid := []string{
+		`{"version": 3, "tiles": []}`,
+		`{"version": 2, "tiles": [{"name": "a.png", "connections": {"north": "A"}}]}`,
+		`{"version": 2, "tiles": [{"name": "a.png", "connections": {}, "weight": -1}]}`,
+		`{"version": 2, "tiles": [{"name": "a.png", "connections": {}, "transform": "X"}]}`,
+	}
+	for _, config := range invalid {
+		if _, err := Parse([]byte(config), JSON, "."); err == nil {
+			t.Errorf("Failed, expected error for %s", config)
+		}
+	}
+}
+
+func Test_Migrate(t *testing.T) {
+	dir := t.TempDir()
+	v1 := `[{"name": "a.png", "connections": {"0": "AAA", "1": "BBB", "2": "CCC", "3": "DDD"}}]`
+	if err := os.WriteFile(path.Join(dir, JSON.FileName()), []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := Migrate(dir)
+	if err != nil || !migrated {
+		t.Fatalf("Failed, expected migration, got %v with err %v", migrated, err)
+	}
+
+	backup, err := os.ReadFile(path.Join(dir, "config.v1.json"))
+	if err != nil || string(backup) != v1 {
+		t.Errorf("Failed, expected original config kept as backup, got %s with err %v", backup, err)
+	}
+
+	data, err := os.ReadFile(path.Join(dir, JSON.FileName()))
+	if err != nil || !strings.Contains(string(data), `"left": "AAA"`) {
+		t.Errorf("Failed, expected named directions in migrated config, got %s with err %v", data, err)
+	}
+
+	migrated, err = Migrate(dir)
+	if err != nil || migrated {
+		t.Errorf("Failed, expected current config left alone, got %v with err %v", migrated, err)
+	}
+}
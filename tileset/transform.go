@@ -0,0 +1,123 @@
+package tileset
+
+import (
+	"fmt"
+	"image"
+	"wavefunctioncollapse/wfc"
+
+	"github.com/disintegration/imaging"
+)
+
+// Transform is a sequence of operations applied to a tile's image, read left to right
+// R rotates 90 degrees clockwise, H mirrors left to right, V mirrors top to bottom
+// F rotates 180 degrees, the same as RR, kept so older configs still load
+// Tiles carry their transform in the config, so variants only exist in memory and are drawn from the base image
+type Transform string
+
+// Checks every operation in the transform is supported
+func (transform Transform) Validate() error {
+	for _, op := range transform {
+		switch op {
+		case 'R', 'H', 'V', 'F':
+		default:
+			return fmt.Errorf("unsupported transform op %c in %q", op, transform)
+		}
+	}
+
+	return nil
+}
+
+// Returns the image with the transform applied
+func (transform Transform) Apply(img image.Image) image.Image {
+	for _, op := range transform {
+		switch op {
+		case 'R':
+			// god knows why, but the rotation is counter-clockwise
+			img = imaging.Rotate270(img)
+		case 'H':
+			img = imaging.FlipH(img)
+		case 'V':
+			img = imaging.FlipV(img)
+		case 'F':
+			img = imaging.FlipH(img)
+			img = imaging.FlipV(img)
+		}
+	}
+
+	return img
+}
+
+// Returns the transform that undoes this one, applying both leaves a tile unchanged
+func (transform Transform) Inverse() Transform {
+	inverse := make([]byte, 0, len(transform)*3)
+	for idx := len(transform) - 1; idx >= 0; idx-- {
+		if transform[idx] == 'R' {
+			// Three clockwise turns undo one
+			inverse = append(inverse, 'R', 'R', 'R')
+			continue
+		}
+		// Mirrors and half turns undo themselves
+		inverse = append(inverse, transform[idx])
+	}
+
+	return Transform(inverse)
+}
+
+// Returns the connections of a tile after the transform is applied to it
+func (transform Transform) Connections(connections map[int]string) map[int]string {
+	for _, op := range transform {
+		switch op {
+		case 'R':
+			connections = map[int]string{
+				wfc.LEFT:  connections[wfc.DOWN],
+				wfc.UP:    connections[wfc.LEFT],
+				wfc.RIGHT: connections[wfc.UP],
+				wfc.DOWN:  connections[wfc.RIGHT],
+			}
+		case 'H':
+			// Mirroring reverses the direction edges are read in, so every connector is reversed
+			connections = map[int]string{
+				wfc.LEFT:  reverse(connections[wfc.RIGHT]),
+				wfc.UP:    reverse(connections[wfc.UP]),
+				wfc.RIGHT: reverse(connections[wfc.LEFT]),
+				wfc.DOWN:  reverse(connections[wfc.DOWN]),
+			}
+		case 'V':
+			connections = map[int]string{
+				wfc.LEFT:  reverse(connections[wfc.LEFT]),
+				wfc.UP:    reverse(connections[wfc.DOWN]),
+				wfc.RIGHT: reverse(connections[wfc.RIGHT]),
+				wfc.DOWN:  reverse(connections[wfc.UP]),
+			}
+		case 'F':
+			connections = map[int]string{
+				wfc.LEFT:  connections[wfc.RIGHT],
+				wfc.UP:    connections[wfc.DOWN],
+				wfc.RIGHT: connections[wfc.LEFT],
+				wfc.DOWN:  connections[wfc.UP],
+			}
+		}
+	}
+
+	return connections
+}
+
+// Returns a variant of the tile with the transform applied after any it already has
+// The variant keeps the base image name, its image is only produced when it's drawn
+func (tile Tile) Transformed(transform Transform) (Tile, error) {
+	if err := transform.Validate(); err != nil {
+		return tile, err
+	}
+
+	tile.Connections = transform.Connections(tile.Connections)
+	tile.Transform += transform
+	return tile, nil
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,84 @@
+package tileset
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func Test_Tile_Transformed_mirror(t *testing.T) {
+	tile := Tile{Name: "tile.png", Connections: map[int]string{0: "ABC", 1: "DEF", 2: "GHI", 3: "JKL"}, Transform: "R"}
+
+	mirrored, err := tile.Transformed("H")
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	expected := map[int]string{0: "IHG", 1: "FED", 2: "CBA", 3: "LKJ"}
+	for dir, connector := range expected {
+		if mirrored.Connections[dir] != connector {
+			t.Errorf("Failed, direction %v expected %v, got %v", dir, connector, mirrored.Connections[dir])
+		}
+	}
+
+	if mirrored.Name != "tile.png" || mirrored.Transform != "RH" {
+		t.Errorf("Failed, expected %v with transform %v, got %v with transform %v", "tile.png", "RH", mirrored.Name, mirrored.Transform)
+	}
+}
+
+func Test_Transform_Connections(t *testing.T) {
+	connections := map[int]string{0: "ABC", 1: "DEF", 2: "GHI", 3: "JKL"}
+	testCases := []struct {
+		transform Transform
+		expected  map[int]string
+	}{
+		{"R", map[int]string{0: "JKL", 1: "ABC", 2: "DEF", 3: "GHI"}},
+		{"H", map[int]string{0: "IHG", 1: "FED", 2: "CBA", 3: "LKJ"}},
+		{"V", map[int]string{0: "CBA", 1: "LKJ", 2: "IHG", 3: "FED"}},
+		{"F", map[int]string{0: "GHI", 1: "JKL", 2: "ABC", 3: "DEF"}},
+		{"HV", map[int]string{0: "GHI", 1: "JKL", 2: "ABC", 3: "DEF"}},
+	}
+
+	for _, tc := range testCases {
+		res := tc.transform.Connections(connections)
+		for dir, connector := range tc.expected {
+			if res[dir] != connector {
+				t.Errorf("Failed, %v direction %v expected %v, got %v", tc.transform, dir, connector, res[dir])
+			}
+		}
+	}
+}
+
+func Test_Transform_Inverse(t *testing.T) {
+	connections := map[int]string{0: "ABC", 1: "DEF", 2: "GHI", 3: "JKL"}
+	for _, transform := range []Transform{"", "R", "RR", "RH", "HR", "VRF"} {
+		res := transform.Inverse().Connections(transform.Connections(connections))
+		if !reflect.DeepEqual(connections, res) {
+			t.Errorf("Failed, %v then %v expected %v, got %v", transform, transform.Inverse(), connections, res)
+		}
+	}
+}
+
+func Test_Transform_Apply_mirrors(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, white)
+
+	testCases := []struct {
+		transform Transform
+		expected  image.Point
+	}{
+		{"H", image.Point{1, 0}},
+		{"V", image.Point{0, 1}},
+		{"R", image.Point{1, 0}},
+		{"F", image.Point{1, 1}},
+	}
+
+	for _, tc := range testCases {
+		res := tc.transform.Apply(img)
+		if res.At(tc.expected.X, tc.expected.Y) != color.Color(white) {
+			t.Errorf("Failed, %v expected white pixel at %v", tc.transform, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	imageprocess "wavefunctioncollapse/imageProcess"
+	"wavefunctioncollapse/tiled"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+var processCommand = command{
+	name:    "process",
+	args:    "<tileset dir>",
+	summary: "write the rotated and mirrored variants of a tileset",
+	help: "Writes a config with every tile and its distinct rotated and mirrored variants to -out.\n" +
+		"No images are written, variants reference the original images with a transform.\n" +
+		"The tileset is never modified and -out is replaced on every run.",
+	run: runProcess,
+}
+
+var packCommand = command{
+	name:    "pack",
+	args:    "<tileset dir>",
+	summary: "draw every tile into a single atlas image",
+	help:    "Draws every tile, with its transform applied, into one atlas image and writes a config for it to -out.",
+	run:     runPack,
+}
+
+var extractCommand = command{
+	name:    "extract",
+	args:    "<tileset dir>",
+	summary: "derive connectors from the pixels along tile edges",
+	help: "Samples the edges of every PNG and atlas tile in the directory and writes a config for them.\n" +
+		"Colours within -tolerance of each other share a connector character.",
+	run: runExtract,
+}
+
+var migrateCommand = command{
+	name:    "migrate",
+	args:    "<tileset dir>",
+	summary: "rewrite a tileset config to the current version",
+	help:    "Rewrites the config to the current schema version, keeping the original as config.v<version>.json.",
+	run:     runMigrate,
+}
+
+var validateCommand = command{
+	name:    "validate",
+	args:    "<tileset dir>",
+	summary: "check a tileset for problems",
+	help: "Checks the tileset for missing images, images of different sizes, missing or uneven connectors,\n" +
+		"duplicate tiles and edges no tile can match. Exits with status 1 if any problems are found.",
+	run: runValidate,
+}
+
+var analyzeCommand = command{
+	name:    "analyze",
+	args:    "<tileset dir>",
+	summary: "check what a tileset can tile before generating",
+	help: "Lists dead tiles and the smallest periodic tilings, and whether a -width by -height grid is\n" +
+		"satisfiable, unsatisfiable or unknown. Exits with status 1 if the grid is unsatisfiable.",
+	run: runAnalyze,
+}
+
+var tsxCommand = command{
+	name:    "tsx",
+	args:    "<tileset dir>",
+	summary: "write a tileset as a Tiled tileset",
+	help: "Writes the tileset to a Tiled .tsx tileset at -out, with connectors, weights and tags as tile properties.\n" +
+		"Variants of an image share one Tiled tile, Tiled maps place them flipped and rotated.",
+	run: runTsx,
+}
+
+var importCommand = command{
+	name:    "import",
+	args:    "<tsx file> <tileset dir>",
+	summary: "create a tileset from a Tiled tileset",
+	help: "Writes a config to the tileset directory for a Tiled .tsx tileset, reading connectors from the\n" +
+		"left, up, right and down properties of each tile. Images are referenced where they are, not copied.",
+	run: runImport,
+}
+
+func runProcess(flags *flag.FlagSet, args []string) int {
+	out := flags.String("out", "", "directory to write the processed tileset to, defaults to <tileset dir>/generated")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	outDir := *out
+	if outDir == "" {
+		outDir = path.Join(positional[0], "generated")
+	}
+
+	if err := imageprocess.ProcessDir(positional[0], outDir); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runPack(flags *flag.FlagSet, args []string) int {
+	out := flags.String("out", "", "directory to write the packed atlas to, defaults to <tileset dir>/atlas")
+	columns := flags.Int("columns", 0, "width in tiles of the atlas, defaults to roughly square")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	outDir := *out
+	if outDir == "" {
+		outDir = path.Join(positional[0], "atlas")
+	}
+
+	if err := imageprocess.PackDir(positional[0], outDir, *columns); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runExtract(flags *flag.FlagSet, args []string) int {
+	samples := flags.Int("samples", 3, "points sampled along each tile edge, the length of every connector")
+	tolerance := flags.Int("tolerance", 16, "maximum colour channel difference (0-255) to treat samples as the same connector")
+	overwrite := flags.Bool("overwrite", false, "replace an existing config")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	opts := imageprocess.ExtractOptions{Samples: *samples, Tolerance: *tolerance}
+	if err := imageprocess.ExtractDir(positional[0], opts, *overwrite); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runMigrate(flags *flag.FlagSet, args []string) int {
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	migrated, err := tileset.Migrate(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	if migrated {
+		fmt.Printf("migrated %s to version %d\n", positional[0], tileset.CurrentVersion)
+	} else {
+		fmt.Printf("%s is already version %d\n", positional[0], tileset.CurrentVersion)
+	}
+	return exitOK
+}
+
+func runValidate(flags *flag.FlagSet, args []string) int {
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	problems, err := imageprocess.Validate(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+
+	if len(problems) > 0 {
+		noun := "problems"
+		if len(problems) == 1 {
+			noun = "problem"
+		}
+		fmt.Printf("%d %s found in %s\n", len(problems), noun, positional[0])
+		return exitFailure
+	}
+	fmt.Printf("no problems found in %s\n", positional[0])
+	return exitOK
+}
+
+func runAnalyze(flags *flag.FlagSet, args []string) int {
+	width := flags.Int("width", 0, "width of grid to check, defaults to the tileset's width or 32")
+	height := flags.Int("height", 0, "height of grid to check, defaults to the tileset's height or 18")
+	border := flags.String("border", "", "connectors required on the grid's edges, e.g. left=AAA,up=BBB")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	gridBorder := wfc.Border{}
+	for _, rule := range strings.Split(*border, ",") {
+		if rule == "" {
+			continue
+		}
+
+		name, connector, ok := strings.Cut(rule, "=")
+		dir, err := tileset.ParseDirection(name)
+		if !ok || err != nil {
+			fmt.Fprintf(flags.Output(), "invalid border rule %q, expected <left|up|right|down>=<connector>\n", rule)
+			return exitUsage
+		}
+		gridBorder[dir] = connector
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+	config := ts.Tiles
+
+	gridWidth, gridHeight := gridSize(ts.Settings, *width, *height)
+	analysis := wfc.Analyze(ts.WfcTiles(), gridWidth, gridHeight, gridBorder, wfc.AnalysisOptions{})
+	if len(analysis.DeadTiles) > 0 {
+		fmt.Println("dead tiles, only usable against a border:")
+		for _, id := range analysis.DeadTiles {
+			fmt.Printf("  %s\n", config[id].Label())
+		}
+	}
+
+	if len(analysis.Periodic) == 0 {
+		fmt.Println("no periodic tiling found")
+	}
+	for _, tiling := range analysis.Periodic {
+		fmt.Printf("%dx%d periodic tiling:\n", tiling.Width, tiling.Height)
+		for y := 0; y < tiling.Height; y++ {
+			names := make([]string, tiling.Width)
+			for x := range names {
+				names[x] = config[tiling.Tiles[x][y]].Label()
+			}
+			fmt.Printf("  %s\n", strings.Join(names, " "))
+		}
+	}
+
+	fmt.Printf("%dx%d grid: %s, %s\n", gridWidth, gridHeight, analysis.Result, analysis.Reason)
+	if analysis.Result == wfc.Unsatisfiable {
+		return exitFailure
+	}
+	return exitOK
+}
+
+func runTsx(flags *flag.FlagSet, args []string) int {
+	out := flags.String("out", "", "file to write the Tiled tileset to, defaults to <tileset dir>/<tileset name>.tsx")
+	positional, code, ok := parseFlags(flags, args, 1)
+	if !ok {
+		return code
+	}
+
+	ts, err := tileset.Load(positional[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	outPath := *out
+	if outPath == "" {
+		name := ts.Settings.Name
+		if name == "" {
+			name = path.Base(ts.Dir())
+		}
+		outPath = path.Join(positional[0], name+".tsx")
+	}
+
+	if _, err := tiled.WriteTileset(ts, outPath); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runImport(flags *flag.FlagSet, args []string) int {
+	overwrite := flags.Bool("overwrite", false, "replace an existing config")
+	positional, code, ok := parseFlags(flags, args, 2)
+	if !ok {
+		return code
+	}
+
+	tsxPath, outDir := positional[0], positional[1]
+	if _, _, err := tileset.FindConfig(outDir); err == nil && !*overwrite {
+		return fail(fmt.Errorf("%s already has a config, pass -overwrite to replace it", outDir))
+	}
+
+	ts, err := tiled.Import(tsxPath, outDir)
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fail(err)
+	}
+	if err := ts.Save(outDir); err != nil {
+		return fail(err)
+	}
+
+	missing := 0
+	for _, tile := range ts.Tiles {
+		if len(tile.Connections) < len(tileset.DirectionNames) {
+			missing++
+		}
+	}
+
+	fmt.Printf("imported %d tiles from %s to %s\n", len(ts.Tiles), tsxPath, outDir)
+	if missing > 0 {
+		fmt.Printf("%d tiles are missing connectors, add left, up, right and down properties in Tiled or edit the config, then run validate\n", missing)
+	}
+	return exitOK
+}
@@ -0,0 +1,15 @@
+//go:build gui
+
+package main
+
+import (
+	"wavefunctioncollapse/gui"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Opens the ebiten window, ebiten needs a display as soon as it's imported so it's only built with the gui tag
+func openViewer(ts *tileset.Tileset, width, height int, constraints []wfc.Constraint) error {
+	gui.RunSimulation(ts, width, height, constraints)
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !gui
+
+package main
+
+import (
+	"fmt"
+	"wavefunctioncollapse/tileset"
+	"wavefunctioncollapse/wfc"
+)
+
+// Builds without the gui tag have no window to open, so every other command runs without a display
+func openViewer(ts *tileset.Tileset, width, height int, constraints []wfc.Constraint) error {
+	return fmt.Errorf("built without the gui, rebuild with -tags gui to view %s, or use generate to write a PNG", ts.Dir())
+}
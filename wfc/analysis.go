@@ -0,0 +1,391 @@
+package wfc
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Satisfiability is whether a tileset can fill a grid
+type Satisfiability int
+
+const (
+	Unknown       Satisfiability = iota // the search gave up before reaching an answer
+	Satisfiable                         // a valid tiling exists
+	Unsatisfiable                       // no valid tiling exists
+)
+
+func (sat Satisfiability) String() string {
+	switch sat {
+	case Satisfiable:
+		return "satisfiable"
+	case Unsatisfiable:
+		return "unsatisfiable"
+	default:
+		return "unknown"
+	}
+}
+
+// Border is the connector tiles must present on each edge of the grid, keyed by direction
+// Directions without a connector allow any tile on that edge
+type Border map[int]string
+
+// AnalysisOptions bounds how much searching Analyze does, zero values use the defaults
+type AnalysisOptions struct {
+	MaxPeriod int // largest width and height of periodic tilings to look for, defaults to 4
+	MaxNodes  int // search nodes to try before giving up with Unknown, defaults to 100000
+}
+
+// Tiling is a grid of tile IDs, indexed [x][y] like the result of Collapse
+type Tiling struct {
+	Width, Height int
+	Tiles         [][]int
+}
+
+// Analysis describes what a tileset can tile
+type Analysis struct {
+	DeadTiles []int          // tiles that can't appear in a tiling of the infinite plane, they can only sit against a border
+	Periodic  []Tiling       // the smallest tilings that repeat to fill the plane, empty if none were found
+	Result    Satisfiability // whether the grid asked about can be tiled
+	Reason    string         // why the result was reached
+}
+
+// Analyzes the rules of a tileset before generating, finding dead tiles and the smallest periodic tilings,
+// and working out if a width by height grid with the given border can be tiled
+func Analyze(tiles []Tile, width, height int, border Border, opts AnalysisOptions) Analysis {
+	if opts.MaxPeriod <= 0 {
+		opts.MaxPeriod = 4
+	}
+	if opts.MaxNodes <= 0 {
+		opts.MaxNodes = 100000
+	}
+
+	rules := compileRules(tiles)
+	analysis := Analysis{DeadTiles: rules.deadTiles()}
+
+	// Look for repeating tilings from the smallest area up, the first area with any is the minimum
+	for area := 1; area <= opts.MaxPeriod*opts.MaxPeriod && len(analysis.Periodic) == 0; area++ {
+		for periodWidth := 1; periodWidth <= opts.MaxPeriod; periodWidth++ {
+			if area%periodWidth != 0 || area/periodWidth > opts.MaxPeriod {
+				continue
+			}
+
+			grid := newRuleGrid(rules, periodWidth, area/periodWidth, true, nil)
+			if tiling, sat := grid.solve(opts.MaxNodes); sat == Satisfiable {
+				analysis.Periodic = append(analysis.Periodic, tiling)
+			}
+		}
+	}
+
+	grid := newRuleGrid(rules, width, height, false, border)
+	switch {
+	case width <= 0 || height <= 0:
+		analysis.Result, analysis.Reason = Unsatisfiable, fmt.Sprintf("grid size %dx%d has no positions", width, height)
+	case !grid.propagateAll():
+		analysis.Result, analysis.Reason = Unsatisfiable, "ruling out tiles that can't fit their neighbours or the border leaves a position with no tiles"
+	case len(border) == 0 && len(analysis.Periodic) > 0:
+		periodic := analysis.Periodic[0]
+		analysis.Result, analysis.Reason = Satisfiable, fmt.Sprintf("the %dx%d periodic tiling repeats to fill any grid", periodic.Width, periodic.Height)
+	default:
+		_, analysis.Result = grid.solve(opts.MaxNodes)
+		switch analysis.Result {
+		case Satisfiable:
+			analysis.Reason = "found a tiling of the grid"
+		case Unsatisfiable:
+			analysis.Reason = "searched every tiling of the grid without finding a valid one"
+		default:
+			analysis.Reason = fmt.Sprintf("gave up after searching %d tilings", opts.MaxNodes)
+		}
+	}
+
+	return analysis
+}
+
+// bitset holds a set of tile indexes
+type bitset []uint64
+
+func newBitset(size int) bitset {
+	return make(bitset, (size+63)/64)
+}
+
+func (set bitset) add(idx int) {
+	set[idx/64] |= 1 << (idx % 64)
+}
+
+func (set bitset) has(idx int) bool {
+	return set[idx/64]&(1<<(idx%64)) != 0
+}
+
+func (set bitset) count() int {
+	total := 0
+	for _, word := range set {
+		total += bits.OnesCount64(word)
+	}
+	return total
+}
+
+// Returns the indexes in the set
+func (set bitset) members() []int {
+	res := make([]int, 0, set.count())
+	for wordIdx, word := range set {
+		for word != 0 {
+			res = append(res, wordIdx*64+bits.TrailingZeros64(word))
+			word &= word - 1
+		}
+	}
+	return res
+}
+
+func (set bitset) clone() bitset {
+	res := make(bitset, len(set))
+	copy(res, set)
+	return res
+}
+
+// ruleset is the compiled adjacency rules of a tileset
+type ruleset struct {
+	tiles      []Tile
+	compatible [4][]bitset // compatible[dir][tile] is the tiles that can sit in dir of tile
+}
+
+func compileRules(tiles []Tile) ruleset {
+	rules := ruleset{tiles: tiles}
+	for dir := LEFT; dir <= DOWN; dir++ {
+		rules.compatible[dir] = make([]bitset, len(tiles))
+		for idx, tile := range tiles {
+			rules.compatible[dir][idx] = newBitset(len(tiles))
+			for otherIdx, other := range tiles {
+				if match(dir, tile, other) {
+					rules.compatible[dir][idx].add(otherIdx)
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// Returns the IDs of tiles that can't appear in a tiling of the infinite plane
+// A tile is dead if some direction has no live tile that can sit there, removing it can kill others in turn
+func (rules ruleset) deadTiles() []int {
+	alive := make([]bool, len(rules.tiles))
+	for idx := range alive {
+		alive[idx] = true
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for idx := range rules.tiles {
+			if !alive[idx] {
+				continue
+			}
+
+			for dir := LEFT; dir <= DOWN; dir++ {
+				hasNeighbour := false
+				for _, other := range rules.compatible[dir][idx].members() {
+					hasNeighbour = hasNeighbour || alive[other]
+				}
+
+				if !hasNeighbour {
+					alive[idx] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	dead := make([]int, 0)
+	for idx, tile := range rules.tiles {
+		if !alive[idx] {
+			dead = append(dead, tile.Id)
+		}
+	}
+	return dead
+}
+
+// ruleGrid is a grid of candidate tiles used to search for tilings, optionally wrapping at the edges
+type ruleGrid struct {
+	rules         ruleset
+	width, height int
+	wrap          bool
+	domains       []bitset // candidates for each position, indexed x*height+y
+}
+
+// Returns a grid where every position can be any tile, apart from border positions which must present the border's connectors
+func newRuleGrid(rules ruleset, width, height int, wrap bool, border Border) *ruleGrid {
+	grid := &ruleGrid{rules: rules, width: width, height: height, wrap: wrap}
+	if width <= 0 || height <= 0 {
+		return grid
+	}
+
+	grid.domains = make([]bitset, width*height)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			domain := newBitset(len(rules.tiles))
+			for idx, tile := range rules.tiles {
+				onBorder := map[int]bool{LEFT: x == 0, UP: y == 0, RIGHT: x == width-1, DOWN: y == height-1}
+				fits := true
+				for dir, connector := range border {
+					if onBorder[dir] && tile.Configuration[dir] != connector {
+						fits = false
+					}
+				}
+
+				if fits {
+					domain.add(idx)
+				}
+			}
+			grid.domains[x*height+y] = domain
+		}
+	}
+
+	return grid
+}
+
+// Returns the index of the neighbouring position in a direction, -1 if it's off the grid
+func (grid *ruleGrid) neighbour(cell, dir int) int {
+	x, y := cell/grid.height, cell%grid.height
+	switch dir {
+	case LEFT:
+		x--
+	case RIGHT:
+		x++
+	case UP:
+		y--
+	case DOWN:
+		y++
+	}
+
+	if grid.wrap {
+		x, y = (x+grid.width)%grid.width, (y+grid.height)%grid.height
+	} else if x < 0 || x >= grid.width || y < 0 || y >= grid.height {
+		return -1
+	}
+	return x*grid.height + y
+}
+
+// Removes candidates that no candidate of a neighbour can sit next to, starting from the given positions
+// Returns false if a position is left with no candidates
+func (grid *ruleGrid) propagate(queue []int) bool {
+	queued := make(map[int]bool, len(queue))
+	for _, cell := range queue {
+		queued[cell] = true
+	}
+
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		queued[cell] = false
+
+		for dir := LEFT; dir <= DOWN; dir++ {
+			next := grid.neighbour(cell, dir)
+			if next == -1 {
+				continue
+			}
+
+			allowed := newBitset(len(grid.rules.tiles))
+			for _, tile := range grid.domains[cell].members() {
+				for word := range allowed {
+					allowed[word] |= grid.rules.compatible[dir][tile][word]
+				}
+			}
+
+			changed := false
+			for word := range allowed {
+				reduced := grid.domains[next][word] & allowed[word]
+				changed = changed || reduced != grid.domains[next][word]
+				grid.domains[next][word] = reduced
+			}
+
+			if !changed {
+				continue
+			}
+			if grid.domains[next].count() == 0 {
+				return false
+			}
+			if !queued[next] {
+				queue = append(queue, next)
+				queued[next] = true
+			}
+		}
+	}
+
+	return true
+}
+
+// Propagates from every position, returns false if a position is left with no candidates
+func (grid *ruleGrid) propagateAll() bool {
+	queue := make([]int, len(grid.domains))
+	for cell := range queue {
+		if grid.domains[cell].count() == 0 {
+			return false
+		}
+		queue[cell] = cell
+	}
+	return grid.propagate(queue)
+}
+
+// Searches for a tiling by choosing the most constrained position and trying each candidate in turn
+// Gives up with Unknown after trying maxNodes candidates
+func (grid *ruleGrid) solve(maxNodes int) (Tiling, Satisfiability) {
+	if len(grid.domains) == 0 || !grid.propagateAll() {
+		return Tiling{}, Unsatisfiable
+	}
+
+	nodes := 0
+	var search func(domains []bitset) ([]bitset, Satisfiability)
+	search = func(domains []bitset) ([]bitset, Satisfiability) {
+		best, bestCount := -1, 0
+		for cell, domain := range domains {
+			if count := domain.count(); count > 1 && (best == -1 || count < bestCount) {
+				best, bestCount = cell, count
+			}
+		}
+
+		if best == -1 {
+			return domains, Satisfiable
+		}
+
+		result := Unsatisfiable
+		for _, tile := range domains[best].members() {
+			if nodes >= maxNodes {
+				return nil, Unknown
+			}
+			nodes++
+
+			grid.domains = make([]bitset, len(domains))
+			for cell := range domains {
+				grid.domains[cell] = domains[cell].clone()
+			}
+			grid.domains[best] = newBitset(len(grid.rules.tiles))
+			grid.domains[best].add(tile)
+
+			if !grid.propagate([]int{best}) {
+				continue
+			}
+
+			solved, sat := search(grid.domains)
+			if sat == Satisfiable {
+				return solved, sat
+			}
+			if sat == Unknown {
+				result = Unknown
+			}
+		}
+
+		return nil, result
+	}
+
+	solved, sat := search(grid.domains)
+	if sat != Satisfiable {
+		return Tiling{}, sat
+	}
+
+	tiling := Tiling{Width: grid.width, Height: grid.height, Tiles: make([][]int, grid.width)}
+	for x := range tiling.Tiles {
+		tiling.Tiles[x] = make([]int, grid.height)
+		for y := range tiling.Tiles[x] {
+			tiling.Tiles[x][y] = grid.rules.tiles[solved[x*grid.height+y].members()[0]].Id
+		}
+	}
+	return tiling, Satisfiable
+}
@@ -0,0 +1,80 @@
+package wfc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Analyze(t *testing.T) {
+	// Tiles 1 and 2 alternate left to right, tile 3 has an edge nothing matches
+	alternating := []Tile{
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "BBB", UP: "CCC", RIGHT: "AAA", DOWN: "CCC"}},
+		{Id: 3, Configuration: map[int]string{LEFT: "AAA", UP: "ZZZ", RIGHT: "BBB", DOWN: "CCC"}},
+	}
+	// No tile can sit next to another horizontally
+	horizontalMismatch := []Tile{
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+	}
+
+	testCases := []struct {
+		name           string
+		tiles          []Tile
+		width, height  int
+		border         Border
+		expected       Satisfiability
+		expectedDead   []int
+		expectedPeriod [2]int
+	}{
+		{"Uniform tiles, any grid", generateTileSet(2), 50, 50, nil, Satisfiable, []int{}, [2]int{1, 1}},
+		{"Alternating tiles, dead tile", alternating, 5, 5, nil, Satisfiable, []int{3}, [2]int{2, 1}},
+		{"Horizontal mismatch, single column", horizontalMismatch, 1, 3, nil, Satisfiable, []int{1, 2}, [2]int{}},
+		{"Horizontal mismatch, two columns", horizontalMismatch, 2, 3, nil, Unsatisfiable, []int{1, 2}, [2]int{}},
+		{"Border nothing presents", generateTileSet(2), 3, 3, Border{LEFT: "BBB"}, Unsatisfiable, []int{}, [2]int{1, 1}},
+		{"Border every tile presents", generateTileSet(2), 3, 3, Border{LEFT: "AAA"}, Satisfiable, []int{}, [2]int{1, 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			analysis := Analyze(tc.tiles, tc.width, tc.height, tc.border, AnalysisOptions{})
+			if analysis.Result != tc.expected {
+				t.Errorf("Failed, expected %v, got %v (%v)", tc.expected, analysis.Result, analysis.Reason)
+			}
+
+			if !reflect.DeepEqual(analysis.DeadTiles, tc.expectedDead) {
+				t.Errorf("Failed, expected dead tiles %v, got %v", tc.expectedDead, analysis.DeadTiles)
+			}
+
+			if tc.expectedPeriod == [2]int{} {
+				if len(analysis.Periodic) != 0 {
+					t.Errorf("Failed, expected no periodic tilings, got %v", analysis.Periodic)
+				}
+				return
+			}
+
+			if len(analysis.Periodic) == 0 {
+				t.Fatalf("Failed, expected a %v periodic tiling, got none", tc.expectedPeriod)
+			}
+
+			periodic := analysis.Periodic[0]
+			if periodic.Width != tc.expectedPeriod[0] || periodic.Height != tc.expectedPeriod[1] {
+				t.Errorf("Failed, expected %v periodic tiling, got %vx%v", tc.expectedPeriod, periodic.Width, periodic.Height)
+			}
+		})
+	}
+}
+
+func Test_Analyze_searchLimit(t *testing.T) {
+	// Every tile fits everywhere, so nothing is ruled out by propagation and each of the 16 positions takes a search node
+	// The border rules out the periodic shortcut, so the search has to run
+	tiles, border := generateTileSet(2), Border{UP: "AAA"}
+	if analysis := Analyze(tiles, 4, 4, border, AnalysisOptions{}); analysis.Result != Satisfiable {
+		t.Fatalf("Failed, expected %v without a limit, got %v", Satisfiable, analysis.Result)
+	}
+
+	analysis := Analyze(tiles, 4, 4, border, AnalysisOptions{MaxNodes: 15})
+	if analysis.Result != Unknown {
+		t.Errorf("Failed, expected %v, got %v", Unknown, analysis.Result)
+	}
+}
@@ -0,0 +1,104 @@
+package wfc
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Outcome of one attempt run by Solve
+type attempt struct {
+	idx    int
+	result [][]int
+	err    error
+}
+
+// Runs opts.Attempts independent solvers at once, each with its own seed, returning the first to succeed and cancelling the rest
+// Runs a single solver if Attempts is 0 or 1. Returns the solver that produced the result, so its seed and counts can be read
+// With a seed in the options, every attempt's seed is derived from it and the lowest numbered attempt to succeed wins,
+// so the same seed always gives the same result as long as only steps and backtracks are limited. Without one, the fastest attempt wins
+// If every attempt fails, returns the first attempt's solver, partial result and error
+// Returns a nil solver and the error from CheckGrid if the grid can't be solved at all
+func Solve(ctx context.Context, tiles []Tile, width, height int, opts Options) (*Solver, [][]int, error) {
+	if err := CheckGrid(tiles, width, height, opts.Constraints); err != nil {
+		return nil, nil, err
+	}
+
+	count := opts.Attempts
+	if count <= 1 {
+		solver := NewSolver(tiles, width, height, opts)
+		result, err := solver.Run(ctx)
+		return solver, result, err
+	}
+
+	deterministic := opts.Seed != 0
+	solvers := make([]*Solver, count)
+	cancels := make([]context.CancelFunc, count)
+	done := make(chan attempt, count) // buffered so cancelled attempts never block once Solve returns
+	for idx, seed := range attemptSeeds(opts.Seed, count) {
+		attemptOpts := opts
+		attemptOpts.Seed = seed
+		// Attempts would overwrite each other's checkpoints
+		attemptOpts.Checkpoint = nil
+		solvers[idx] = NewSolver(tiles, width, height, attemptOpts)
+
+		var attemptCtx context.Context
+		attemptCtx, cancels[idx] = context.WithCancel(ctx)
+		defer cancels[idx]()
+
+		go func(idx int) {
+			result, err := solvers[idx].Run(attemptCtx)
+			done <- attempt{idx, result, err}
+		}(idx)
+	}
+
+	attempts := make([]*attempt, count)
+	best := -1
+	for received := 0; received < count; received++ {
+		finished := <-done
+		attempts[finished.idx] = &finished
+
+		if finished.err == nil && (best < 0 || finished.idx < best) {
+			best = finished.idx
+			// Later attempts can't win any more, and without a seed nothing else can either
+			for idx := range cancels {
+				if idx > best || !deterministic {
+					cancels[idx]()
+				}
+			}
+		}
+
+		if best >= 0 && (!deterministic || allFinished(attempts[:best])) {
+			return solvers[best], attempts[best].result, nil
+		}
+	}
+
+	return solvers[0], attempts[0].result, attempts[0].err
+}
+
+// Returns a seed for each attempt, the first is the given seed, or a random one if it's 0, and the rest are derived from it
+func attemptSeeds(seed int64, count int) []int64 {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	seeds := make([]int64, count)
+	seeds[0] = seed
+	rng := rand.New(rand.NewSource(seed))
+	for idx := 1; idx < count; idx++ {
+		// 0 would ask the solver to pick its own seed
+		for seeds[idx] == 0 {
+			seeds[idx] = rng.Int63()
+		}
+	}
+	return seeds
+}
+
+func allFinished(attempts []*attempt) bool {
+	for _, finished := range attempts {
+		if finished == nil {
+			return false
+		}
+	}
+	return true
+}
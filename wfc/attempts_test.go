@@ -0,0 +1,95 @@
+package wfc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_Solve_deterministic(t *testing.T) {
+	tiles := generateTileSet(5)
+	opts := Options{Seed: 7, Attempts: 4}
+
+	solver, first, err := Solve(context.Background(), tiles, 8, 8, opts)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	// Every attempt succeeds with this tileset, so the first attempt always wins and uses the given seed
+	if solver.Seed() != 7 {
+		t.Errorf("Failed, expected the first attempt to win with seed %v, got %v", 7, solver.Seed())
+	}
+
+	single, err := NewSolver(tiles, 8, 8, Options{Seed: 7}).Run(context.Background())
+	if err != nil || !reflect.DeepEqual(first, single) {
+		t.Errorf("Failed, expected the same result as a single run with the seed, got %v and %v with err %v", first, single, err)
+	}
+
+	for i := 0; i < 5; i++ {
+		_, res, err := Solve(context.Background(), tiles, 8, 8, opts)
+		if err != nil || !reflect.DeepEqual(first, res) {
+			t.Fatalf("Failed, expected the same result every run, got %v and %v with err %v", first, res, err)
+		}
+	}
+}
+
+func Test_Solve_unsatisfiable(t *testing.T) {
+	// No tile can sit next to another horizontally
+	tileSet := []Tile{
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+	}
+
+	_, _, err := Solve(context.Background(), tileSet, 2, 2, Options{Attempts: 3})
+	if !errors.Is(err, ErrUnsatisfiable) {
+		t.Errorf("Failed, expected %v, got %v", ErrUnsatisfiable, err)
+	}
+}
+
+func Test_Solve_random(t *testing.T) {
+	solver, res, err := Solve(context.Background(), generateTileSet(3), 6, 6, Options{Attempts: 3})
+	if err != nil || solver.Seed() == 0 || len(res) != 6 {
+		t.Errorf("Failed, expected a result from a seeded attempt, got %v with seed %v and err %v", res, solver.Seed(), err)
+	}
+}
+
+func Test_attemptSeeds(t *testing.T) {
+	seeds := attemptSeeds(3, 4)
+	if seeds[0] != 3 || !reflect.DeepEqual(seeds, attemptSeeds(3, 4)) {
+		t.Errorf("Failed, expected the same seeds starting with %v, got %v", 3, seeds)
+	}
+
+	seen := make(map[int64]bool)
+	for _, seed := range attemptSeeds(0, 8) {
+		if seed == 0 || seen[seed] {
+			t.Errorf("Failed, expected distinct non-zero seeds, got %v", seed)
+		}
+		seen[seed] = true
+	}
+}
+
+func Test_Solve_invalidGrid(t *testing.T) {
+	testCases := []struct {
+		name          string
+		tiles         []Tile
+		width, height int
+		constraints   []Constraint
+	}{
+		{"Single tile", generateTileSet(1), 2, 2, nil},
+		{"No positions", generateTileSet(2), 0, 2, nil},
+		{"Constraint outside the grid", generateTileSet(2), 2, 2, []Constraint{{X: 2, Y: 0, Tiles: []int{0}}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			solver, res, err := Solve(context.Background(), tc.tiles, tc.width, tc.height, Options{Constraints: tc.constraints, Attempts: 2})
+			if err == nil || solver != nil || res != nil {
+				t.Errorf("Failed, expected an error without a solver, got %v, %v and %v", solver, res, err)
+			}
+			if _, err := CollapseContext(context.Background(), tc.tiles, tc.width, tc.height, Options{Constraints: tc.constraints}); err == nil {
+				t.Errorf("Failed, expected CollapseContext to return an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package wfc
+
+import "sort"
+
+// Most recent decisions kept in a Contradiction's chain
+const chainLength = 16
+
+// Contradiction describes a position the solver couldn't collapse, recorded when Options.Diagnose is set
+type Contradiction struct {
+	Step       int        `json:"step"` // the step the contradiction happened on, counting from 1
+	X          int        `json:"x"`
+	Y          int        `json:"y"`
+	Domain     []int      `json:"domain"`     // IDs of the tiles that could have gone at the position, none of which fit
+	Neighbours [4][]int   `json:"neighbours"` // each neighbour's possible tiles by direction, a collapsed neighbour's tile, null off the grid
+	Conflicts  []Conflict `json:"conflicts"`  // why each tile in the domain was rejected
+	Chain      []Choice   `json:"chain"`      // the latest collapsed positions that led here, oldest first
+}
+
+// Conflict is a side of a tile that no tile left in the neighbour on that side could match
+type Conflict struct {
+	TileId    int      `json:"tileId"`
+	Direction int      `json:"direction"` // side of the tile, LEFT, UP, RIGHT or DOWN
+	Connector string   `json:"connector"` // the tile's connector on that side
+	Needed    string   `json:"needed"`    // connector a neighbour needed on the facing side to match
+	Offered   []string `json:"offered"`   // connectors the neighbour's remaining tiles had on the facing side, sorted
+}
+
+// Choice is a position the solver collapsed and the tile it picked
+type Choice struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	TileId int `json:"tileId"`
+}
+
+// Returns every contradiction recorded so far, only recorded when Options.Diagnose is set
+func (s *Solver) Contradictions() []Contradiction {
+	return s.contradictions
+}
+
+// Describes why the position couldn't be collapsed, called before the solver backtracks so the grid is as it failed
+func (s *Solver) diagnose(pos position) Contradiction {
+	domain := s.grid.tileConfigurations[pos.x][pos.y]
+	contradiction := Contradiction{
+		Step:      s.steps,
+		X:         pos.x,
+		Y:         pos.y,
+		Domain:    make([]int, 0, len(domain)),
+		Conflicts: []Conflict{},
+		Chain:     []Choice{},
+	}
+
+	// The same neighbours collapseTile checks, in the same directions
+	neighbours := [4]position{
+		LEFT:  {pos.x - 1, pos.y},
+		UP:    {pos.x, pos.y - 1},
+		RIGHT: {pos.x + 1, pos.y},
+		DOWN:  {pos.x, pos.y + 1},
+	}
+	for dir, neighbour := range neighbours {
+		contradiction.Neighbours[dir] = s.Domain(neighbour.x, neighbour.y)
+	}
+
+	for _, tile := range domain {
+		contradiction.Domain = append(contradiction.Domain, tile.Id)
+		for dir, neighbour := range neighbours {
+			// Positions off the grid or already collapsed are never checked when collapsing
+			neighbourTiles := s.grid.getTileConfig(neighbour)
+			if neighbourTiles == nil || matchesAny(dir, tile, neighbourTiles) {
+				continue
+			}
+
+			contradiction.Conflicts = append(contradiction.Conflicts, Conflict{
+				TileId:    tile.Id,
+				Direction: dir,
+				Connector: tile.Configuration[dir],
+				Needed:    reverse(tile.Configuration[dir]),
+				Offered:   connectors(neighbourTiles, (dir+2)%4),
+			})
+		}
+	}
+
+	first := s.history.pointer - chainLength
+	if first < 0 {
+		first = 0
+	}
+	for _, decision := range s.history.stackSlice[first:s.history.pointer] {
+		if tile := s.grid.getTileId(decision.pos); tile != nil {
+			contradiction.Chain = append(contradiction.Chain, Choice{X: decision.pos.x, Y: decision.pos.y, TileId: tile.Id})
+		}
+	}
+
+	return contradiction
+}
+
+// Reports if any of the tiles can sit next to tile in the given direction
+func matchesAny(dir int, tile Tile, tiles []Tile) bool {
+	for _, other := range tiles {
+		if match(dir, tile, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the distinct connectors the tiles have on one side, sorted
+func connectors(tiles []Tile, dir int) []string {
+	seen := make(map[string]bool, len(tiles))
+	res := make([]string, 0, len(tiles))
+	for _, tile := range tiles {
+		if connector := tile.Configuration[dir]; !seen[connector] {
+			seen[connector] = true
+			res = append(res, connector)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
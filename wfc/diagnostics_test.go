@@ -0,0 +1,74 @@
+package wfc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_Solver_Diagnose_unsatisfiable(t *testing.T) {
+	// No tile can sit next to another horizontally
+	tileSet := []Tile{
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+	}
+
+	solver := NewSolver(tileSet, 2, 1, Options{Seed: 1, Diagnose: true})
+	solver.Run(context.Background())
+	contradictions := solver.Contradictions()
+	if len(contradictions) != 1 {
+		t.Fatalf("Failed, expected a single contradiction, got %+v", contradictions)
+	}
+
+	contradiction := contradictions[0]
+	if !reflect.DeepEqual(contradiction.Domain, []int{1, 2}) || len(contradiction.Chain) != 0 || contradiction.Step != 1 {
+		t.Errorf("Failed, expected both tiles to be tried on the first step with no decisions before, got %+v", contradiction)
+	}
+
+	// Whichever end the solver started at, each tile fails towards the other position
+	dir, connector, offered := RIGHT, "BBB", []string{"AAA"}
+	if contradiction.X == 1 {
+		dir, connector, offered = LEFT, "AAA", []string{"BBB"}
+	}
+	expected := []Conflict{
+		{TileId: 1, Direction: dir, Connector: connector, Needed: connector, Offered: offered},
+		{TileId: 2, Direction: dir, Connector: connector, Needed: connector, Offered: offered},
+	}
+	if !reflect.DeepEqual(expected, contradiction.Conflicts) {
+		t.Errorf("Failed, expected conflicts %+v, got %+v", expected, contradiction.Conflicts)
+	}
+	if contradiction.Neighbours[dir] == nil || contradiction.Neighbours[UP] != nil {
+		t.Errorf("Failed, expected the neighbour towards %v only, got %v", dir, contradiction.Neighbours)
+	}
+}
+
+func Test_Solver_Diagnose_backtracks(t *testing.T) {
+	var events []Event
+	solver := NewSolver(cornerTileSet(), 8, 8, Options{Seed: 8, Diagnose: true})
+	solver.AddObserver(ObserverFunc(func(event Event) {
+		if event.Type == EventContradiction {
+			events = append(events, event)
+		}
+	}))
+	if _, err := solver.Run(context.Background()); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	contradictions := solver.Contradictions()
+	if len(contradictions) != solver.Backtracks() || len(events) != len(contradictions) {
+		t.Fatalf("Failed, expected a contradiction for each of the %d backtracks, got %d and %d events", solver.Backtracks(), len(contradictions), len(events))
+	}
+
+	for idx, contradiction := range contradictions {
+		if !reflect.DeepEqual(*events[idx].Contradiction, contradiction) {
+			t.Errorf("Failed, expected the event to carry the contradiction %+v, got %+v", contradiction, events[idx].Contradiction)
+		}
+		if len(contradiction.Chain) == 0 || len(contradiction.Chain) > chainLength || len(contradiction.Conflicts) < len(contradiction.Domain) {
+			t.Errorf("Failed, expected a chain of decisions and a conflict for every tile, got %+v", contradiction)
+		}
+	}
+
+	if NewSolver(cornerTileSet(), 8, 8, Options{Seed: 8}).Contradictions() != nil {
+		t.Errorf("Failed, expected nothing recorded without Diagnose")
+	}
+}
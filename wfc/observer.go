@@ -0,0 +1,48 @@
+package wfc
+
+// EventType identifies what happened during a solver step
+type EventType int
+
+const (
+	EventCellCollapsed EventType = iota // a position was collapsed to a single tile
+	EventDomainReduced                  // a neighbour lost possible tiles after a collapse
+	EventBacktrack                      // a previously collapsed position was undone
+	EventContradiction                  // a position had no tile that kept its neighbours valid
+)
+
+func (eventType EventType) String() string {
+	switch eventType {
+	case EventCellCollapsed:
+		return "cell collapsed"
+	case EventDomainReduced:
+		return "domain reduced"
+	case EventBacktrack:
+		return "backtrack"
+	case EventContradiction:
+		return "contradiction"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a single change made by the solver
+type Event struct {
+	Type   EventType
+	X, Y   int   // the position the event happened at
+	TileId int   // the collapsed tile for EventCellCollapsed, the tile ruled out for EventBacktrack
+	Domain []int // the remaining tile IDs for EventDomainReduced and EventBacktrack
+
+	Contradiction *Contradiction // what went wrong for EventContradiction, only set when Options.Diagnose is
+}
+
+// Observer receives events as the solver generates, used by tools to visualize or log generation
+type Observer interface {
+	OnEvent(event Event)
+}
+
+// ObserverFunc allows a plain function to be used as an Observer
+type ObserverFunc func(event Event)
+
+func (fn ObserverFunc) OnEvent(event Event) {
+	fn(event)
+}
@@ -0,0 +1,54 @@
+package wfc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Undecided marks a position in a partial result that was never collapsed
+const Undecided = -1
+
+var (
+	ErrMaxSteps      = errors.New("step limit reached")
+	ErrMaxBacktracks = errors.New("backtrack limit reached")
+	ErrUnsatisfiable = errors.New("no valid tiling found for the tileset")
+)
+
+// Options limits how long a collapse can run and what it produces, zero values mean no limit
+type Options struct {
+	MaxSteps      int           // maximum attempts to collapse a position, including retries after backtracking
+	MaxBacktracks int           // maximum times the algorithm can undo a previously collapsed position
+	Timeout       time.Duration // maximum wall-clock time for the whole collapse
+	Seed          int64         // seeds every random choice so a result can be reproduced, 0 picks a random seed
+	Constraints   []Constraint  // tiles allowed at positions before generation starts, e.g. to fill in part of an existing map
+	Attempts      int           // independent solvers Solve and CollapseContext run at once with different seeds, 0 or 1 runs one
+	Diagnose      bool          // record the details of every contradiction, see Solver.Contradictions
+
+	// Called by Run with the solver's state every CheckpointEvery, and when the context stops it, so the run can be resumed
+	// An error stops the run. Ignored when running several attempts
+	Checkpoint      func(snapshot *Snapshot) error
+	CheckpointEvery time.Duration
+}
+
+// Constraint limits the tiles allowed at a position
+// Positions with a single tile are collapsed first, so the rest of the grid is generated around them
+type Constraint struct {
+	X, Y  int
+	Tiles []int // IDs of the tiles allowed at the position
+}
+
+// StopError describes why a collapse stopped before every position was collapsed
+type StopError struct {
+	Reason     error // ErrMaxSteps, ErrMaxBacktracks, ErrUnsatisfiable or the context's error
+	Steps      int   // steps taken before stopping
+	Backtracks int   // backtracks taken before stopping
+}
+
+func (err *StopError) Error() string {
+	return fmt.Sprintf("generation stopped after %d steps and %d backtracks: %v", err.Steps, err.Backtracks, err.Reason)
+}
+
+func (err *StopError) Unwrap() error {
+	return err.Reason
+}
@@ -0,0 +1,217 @@
+package wfc
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Version of the snapshot format, Resume refuses any other
+const SnapshotVersion = 1
+
+// Snapshot is the complete state of a solver, from which Resume carries on exactly where it stopped
+// The tiles are included, so a snapshot reproduces a generation, or a contradiction, without the tileset it came from
+type Snapshot struct {
+	Version    int        `json:"version"`
+	Tiles      []Tile     `json:"tiles"`
+	Width      int        `json:"width"`
+	Height     int        `json:"height"`
+	Seed       int64      `json:"seed"`
+	Draws      uint64     `json:"draws"` // random numbers taken from the seed so far, replayed to restore the generator
+	Steps      int        `json:"steps"`
+	Backtracks int        `json:"backtracks"`
+	Finished   bool       `json:"finished"`
+	NextX      int        `json:"nextX"` // next position to collapse
+	NextY      int        `json:"nextY"`
+	Domains    [][][]int  `json:"domains"` // IDs of the tiles possible at each position by [x][y], in the order they're picked from, null for every tile in order
+	Collapsed  [][]bool   `json:"collapsed"`
+	History    []Decision `json:"history"` // collapsed positions that can be undone, oldest first
+}
+
+// Decision is a collapsed position the solver can backtrack to, with the domains from before it was collapsed
+type Decision struct {
+	X          int      `json:"x"`
+	Y          int      `json:"y"`
+	Domain     []int    `json:"domain"`
+	Neighbours [4][]int `json:"neighbours"` // indexed by direction, null for positions off the grid or already collapsed
+}
+
+// Source of random numbers counting how many it has given, so the generator can be restored by replaying them from the seed
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+// Returns a generator for the seed that has already given draws random numbers
+func newCountingSource(seed int64, draws uint64) *countingSource {
+	source := &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+	for source.draws < draws {
+		source.Uint64()
+	}
+	return source
+}
+
+func (source *countingSource) Int63() int64 {
+	source.draws++
+	return source.src.Int63()
+}
+
+func (source *countingSource) Uint64() uint64 {
+	source.draws++
+	return source.src.Uint64()
+}
+
+func (source *countingSource) Seed(seed int64) {
+	source.src.Seed(seed)
+	source.draws = 0
+}
+
+// Returns the complete state of the solver, which shares nothing the solver changes
+// Taken between steps, resuming it gives the same result as carrying on with this solver
+func (s *Solver) Snapshot() *Snapshot {
+	width, height := s.Size()
+	snapshot := &Snapshot{
+		Version:    SnapshotVersion,
+		Tiles:      append([]Tile(nil), s.tiles...),
+		Width:      width,
+		Height:     height,
+		Seed:       s.seed,
+		Draws:      s.source.draws,
+		Steps:      s.steps,
+		Backtracks: s.backtracks,
+		Finished:   s.finished,
+		NextX:      s.pos.x,
+		NextY:      s.pos.y,
+		Domains:    make([][][]int, width),
+		Collapsed:  make([][]bool, width),
+		History:    make([]Decision, s.history.pointer),
+	}
+
+	for x := range s.grid.tileConfigurations {
+		snapshot.Domains[x] = make([][]int, height)
+		snapshot.Collapsed[x] = append([]bool(nil), s.grid.positionsCollapsed[x]...)
+		for y, domain := range s.grid.tileConfigurations[x] {
+			if !sameOrder(domain, s.tiles) {
+				snapshot.Domains[x][y] = tileIds(domain)
+			}
+		}
+	}
+
+	for idx, decision := range s.history.stackSlice[:s.history.pointer] {
+		snapshot.History[idx] = Decision{X: decision.pos.x, Y: decision.pos.y, Domain: tileIds(decision.oldTileConfig)}
+		for dir := range snapshot.History[idx].Neighbours {
+			snapshot.History[idx].Neighbours[dir] = tileIds(decision.oldNeighbours[dir])
+		}
+	}
+
+	return snapshot
+}
+
+// Returns a solver carrying on from a snapshot, limited by opts
+// The seed and constraints come from the snapshot, so they're ignored in opts. Steps and backtracks carry on counting from the snapshot's
+func Resume(snapshot *Snapshot, opts Options) (*Solver, error) {
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot is version %d, expected %d", snapshot.Version, SnapshotVersion)
+	}
+	if len(snapshot.Tiles) < 2 {
+		return nil, fmt.Errorf("snapshot has %d tiles, expected at least 2", len(snapshot.Tiles))
+	}
+	if snapshot.Width <= 0 || snapshot.Height <= 0 || len(snapshot.Domains) != snapshot.Width || len(snapshot.Collapsed) != snapshot.Width {
+		return nil, fmt.Errorf("snapshot of a %dx%d grid has %d columns of domains", snapshot.Width, snapshot.Height, len(snapshot.Domains))
+	}
+	if snapshot.NextX < 0 || snapshot.NextX >= snapshot.Width || snapshot.NextY < 0 || snapshot.NextY >= snapshot.Height {
+		return nil, fmt.Errorf("snapshot's next position (%d, %d) is outside the grid", snapshot.NextX, snapshot.NextY)
+	}
+
+	byId := make(map[int]Tile, len(snapshot.Tiles))
+	for _, tile := range snapshot.Tiles {
+		if _, ok := byId[tile.Id]; ok {
+			return nil, fmt.Errorf("snapshot has more than one tile with ID %d", tile.Id)
+		}
+		byId[tile.Id] = tile
+	}
+	// Looks up the tiles of a domain, keeping nil and empty domains apart as the solver does
+	domainTiles := func(ids []int) ([]Tile, error) {
+		if ids == nil {
+			return nil, nil
+		}
+		tiles := make([]Tile, len(ids))
+		for idx, id := range ids {
+			tile, ok := byId[id]
+			if !ok {
+				return nil, fmt.Errorf("snapshot has tile %d, which isn't one of its tiles", id)
+			}
+			tiles[idx] = tile
+		}
+		return tiles, nil
+	}
+
+	// Every position starts with the whole tileset, as newTileGrid does, so only differing domains need replacing
+	grid := newTileGrid(snapshot.Width, snapshot.Height, snapshot.Tiles)
+	for x := range snapshot.Domains {
+		if len(snapshot.Domains[x]) != snapshot.Height || len(snapshot.Collapsed[x]) != snapshot.Height {
+			return nil, fmt.Errorf("snapshot of a %dx%d grid has %d rows in column %d", snapshot.Width, snapshot.Height, len(snapshot.Domains[x]), x)
+		}
+		copy(grid.positionsCollapsed[x], snapshot.Collapsed[x])
+		for y, ids := range snapshot.Domains[x] {
+			if grid.positionsCollapsed[x][y] && len(ids) != 1 {
+				return nil, fmt.Errorf("snapshot's collapsed position (%d, %d) doesn't have a single tile", x, y)
+			}
+			if ids == nil {
+				continue
+			}
+			tiles, err := domainTiles(ids)
+			if err != nil {
+				return nil, err
+			}
+			grid.tileConfigurations[x][y] = tiles
+		}
+	}
+
+	source := newCountingSource(snapshot.Seed, snapshot.Draws)
+	grid.rng = rand.New(source)
+	s := &Solver{
+		grid:       grid,
+		tiles:      append([]Tile(nil), snapshot.Tiles...),
+		pos:        position{snapshot.NextX, snapshot.NextY},
+		opts:       opts,
+		finished:   snapshot.Finished,
+		seed:       snapshot.Seed,
+		source:     source,
+		steps:      snapshot.Steps,
+		backtracks: snapshot.Backtracks,
+	}
+
+	for _, decision := range snapshot.History {
+		if decision.X < 0 || decision.X >= snapshot.Width || decision.Y < 0 || decision.Y >= snapshot.Height {
+			return nil, fmt.Errorf("snapshot's history has position (%d, %d) outside the grid", decision.X, decision.Y)
+		}
+
+		old := oldTile{pos: position{decision.X, decision.Y}, oldNeighbours: make(map[int][]Tile, len(decision.Neighbours))}
+		var err error
+		if old.oldTileConfig, err = domainTiles(decision.Domain); err != nil {
+			return nil, err
+		}
+		for dir, ids := range decision.Neighbours {
+			if old.oldNeighbours[dir], err = domainTiles(ids); err != nil {
+				return nil, err
+			}
+		}
+		s.history.push(old)
+	}
+	s.stats.MaxDepth = s.history.pointer
+
+	return s, nil
+}
+
+// Reports if a domain is every tile in the tileset in its original order
+func sameOrder(domain, tiles []Tile) bool {
+	if len(domain) != len(tiles) {
+		return false
+	}
+	for idx := range domain {
+		if domain[idx].Id != tiles[idx].Id {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package wfc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Tiles that often paint themselves into a corner, so solving them backtracks
+func cornerTileSet() []Tile {
+	return []Tile{
+		{Id: 0, Configuration: map[int]string{LEFT: "A", UP: "A", RIGHT: "A", DOWN: "A"}},
+		{Id: 1, Configuration: map[int]string{LEFT: "A", UP: "B", RIGHT: "C", DOWN: "A"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "C", UP: "A", RIGHT: "A", DOWN: "B"}},
+		{Id: 3, Configuration: map[int]string{LEFT: "C", UP: "C", RIGHT: "C", DOWN: "C"}, Weight: 2},
+		{Id: 4, Configuration: map[int]string{LEFT: "B", UP: "C", RIGHT: "A", DOWN: "C"}},
+	}
+}
+
+// Returns a copy of the snapshot that went through JSON, as one read from disk would
+func roundTrip(t *testing.T, snapshot *Snapshot) *Snapshot {
+	t.Helper()
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	var decoded Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+	return &decoded
+}
+
+func Test_Solver_Resume(t *testing.T) {
+	tiles := cornerTileSet()
+	opts := Options{Seed: 8, Constraints: []Constraint{{X: 2, Y: 2, Tiles: []int{0, 3}}}}
+	whole := NewSolver(tiles, 8, 8, opts)
+	expected, err := whole.Run(context.Background())
+	if err != nil || whole.Backtracks() == 0 {
+		t.Fatalf("Failed, expected a result found after backtracking, got %d backtracks with err %v", whole.Backtracks(), err)
+	}
+
+	for stopAt := 0; stopAt < whole.Steps(); stopAt++ {
+		solver := NewSolver(tiles, 8, 8, opts)
+		for solver.Steps() < stopAt {
+			solver.Step()
+		}
+
+		resumed, err := Resume(roundTrip(t, solver.Snapshot()), Options{})
+		if err != nil {
+			t.Fatalf("Failed, expected no error resuming after %d steps, got %v", stopAt, err)
+		}
+
+		res, err := resumed.Run(context.Background())
+		if err != nil || !reflect.DeepEqual(expected, res) {
+			t.Errorf("Failed, resuming after %d steps expected %v, got %v with err %v", stopAt, expected, res, err)
+		}
+		if resumed.Steps() != whole.Steps() || resumed.Backtracks() != whole.Backtracks() || resumed.Seed() != whole.Seed() {
+			t.Errorf("Failed, resuming after %d steps expected %d steps and %d backtracks, got %d and %d",
+				stopAt, whole.Steps(), whole.Backtracks(), resumed.Steps(), resumed.Backtracks())
+		}
+	}
+}
+
+func Test_Solver_Checkpoint(t *testing.T) {
+	var checkpoints []*Snapshot
+	opts := Options{Seed: 3, Checkpoint: func(snapshot *Snapshot) error {
+		checkpoints = append(checkpoints, snapshot)
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := NewSolver(cornerTileSet(), 4, 4, opts).Run(ctx)
+	if !errors.Is(err, context.Canceled) || len(checkpoints) != 1 || checkpoints[0].Steps != 0 {
+		t.Fatalf("Failed, expected a checkpoint when the context stops the run, got %d checkpoints with err %v", len(checkpoints), err)
+	}
+
+	failing := errors.New("disk full")
+	opts.CheckpointEvery = 1
+	opts.Checkpoint = func(*Snapshot) error { return failing }
+	if _, err := NewSolver(cornerTileSet(), 4, 4, opts).Run(context.Background()); !errors.Is(err, failing) {
+		t.Errorf("Failed, expected the checkpoint's error to stop the run, got %v", err)
+	}
+}
+
+func Test_Resume_invalid(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(snapshot *Snapshot)
+	}{
+		{"Other version", func(snapshot *Snapshot) { snapshot.Version = 99 }},
+		{"Unknown tile", func(snapshot *Snapshot) { snapshot.Domains[0][0] = []int{42} }},
+		{"Missing column", func(snapshot *Snapshot) { snapshot.Domains = snapshot.Domains[1:] }},
+		{"Collapsed without a tile", func(snapshot *Snapshot) { snapshot.Collapsed[1][1], snapshot.Domains[1][1] = true, nil }},
+		{"Duplicate tile", func(snapshot *Snapshot) { snapshot.Tiles[1].Id = 0 }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			snapshot := NewSolver(cornerTileSet(), 3, 3, Options{Seed: 1}).Snapshot()
+			tc.modify(snapshot)
+			if _, err := Resume(snapshot, Options{}); err == nil {
+				t.Errorf("Failed, expected an error")
+			}
+		})
+	}
+}
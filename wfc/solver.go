@@ -0,0 +1,315 @@
+package wfc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Solver runs the collapse algorithm one step at a time, so callers can animate, debug or drive generation
+type Solver struct {
+	grid       tileGrid
+	tiles      []Tile          // the tileset, in the order every position started with
+	source     *countingSource // source of grid.rng, counting its draws for snapshots
+	history    tileStack       // previously collapsed positions, used to backtrack
+	pos        position        // next position to collapse
+	opts       Options         // limits checked on every step
+	observers  []Observer      // notified of every event
+	finished   bool            // true once every position has been collapsed
+	err        error           // set once a limit has stopped the solver
+	seed       int64           // seed of every random choice, from the options or picked when the solver was created
+	steps      int
+	backtracks int
+
+	contradictions []Contradiction // recorded when opts.Diagnose is set
+	stats          Stats           // counted by every step, see Stats
+}
+
+// Returns a new solver for the tileset, ready to collapse its first position
+// Panics if a constraint is outside the grid, like an invalid grid size, CheckGrid reports those as an error instead
+func NewSolver(tiles []Tile, width, height int, opts Options) *Solver {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	grid := newTileGrid(width, height, tiles)
+	source := newCountingSource(seed, 0)
+	grid.rng = rand.New(source)
+	for _, constraint := range opts.Constraints {
+		grid.constrain(position{constraint.X, constraint.Y}, constraint.Tiles)
+	}
+
+	pos := position{grid.rng.Intn(width), grid.rng.Intn(height)}
+	if len(opts.Constraints) > 0 {
+		// Start from the most constrained position so the rest of the grid is built around the constraints
+		pos = *grid.tileWithLowestEntropy()
+	}
+
+	return &Solver{
+		grid:   grid,
+		tiles:  tiles,
+		source: source,
+		pos:    pos,
+		opts:   opts,
+		seed:   seed,
+	}
+}
+
+// Returns an error if NewSolver can't solve the grid: fewer than two tiles, a grid without positions or a constraint outside it
+func CheckGrid(tiles []Tile, width, height int, constraints []Constraint) error {
+	if len(tiles) < 2 {
+		return fmt.Errorf("tileset has %d tiles, at least 2 are needed to generate", len(tiles))
+	}
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("grid size %dx%d has no positions", width, height)
+	}
+	for _, constraint := range constraints {
+		if constraint.X < 0 || constraint.X >= width || constraint.Y < 0 || constraint.Y >= height {
+			return fmt.Errorf("constraint at (%d, %d) is outside the %dx%d grid", constraint.X, constraint.Y, width, height)
+		}
+	}
+	return nil
+}
+
+// Registers an observer to receive every event from future steps
+func (s *Solver) AddObserver(observer Observer) {
+	s.observers = append(s.observers, observer)
+}
+
+// Performs one observation and propagation, collapsing the next position or backtracking if it can't be collapsed
+// Returns the events describing what changed, or a *StopError if a limit was reached or the tileset can't be solved
+// Does nothing once the solver is done
+func (s *Solver) Step() ([]Event, error) {
+	if s.finished || s.err != nil {
+		return nil, s.err
+	}
+
+	if s.opts.MaxSteps > 0 && s.steps >= s.opts.MaxSteps {
+		return nil, s.stop(ErrMaxSteps)
+	}
+	s.steps++
+
+	pos := s.pos
+	events := make([]Event, 0, 5)
+	emit := func(event Event) {
+		events = append(events, event)
+		for _, observer := range s.observers {
+			observer.OnEvent(event)
+		}
+	}
+
+	currTileConf := s.grid.getTileConfig(pos)
+	// Track neighbours before tiles are collapsed incase we need to backtrack
+	neighbours := make(map[int][]Tile)
+	neighbours[UP] = s.grid.getTileConfig(position{pos.x, pos.y + 1})
+	neighbours[RIGHT] = s.grid.getTileConfig(position{pos.x + 1, pos.y})
+	neighbours[DOWN] = s.grid.getTileConfig(position{pos.x, pos.y - 1})
+	neighbours[LEFT] = s.grid.getTileConfig(position{pos.x - 1, pos.y})
+
+	start := time.Now()
+	collapsedTile := s.grid.collapseTile(pos)
+	s.stats.PropagationTime += time.Since(start)
+	if !collapsedTile {
+		s.stats.Contradictions++
+		event := Event{Type: EventContradiction, X: pos.x, Y: pos.y}
+		if s.opts.Diagnose {
+			contradiction := s.diagnose(pos)
+			s.contradictions = append(s.contradictions, contradiction)
+			event.Contradiction = &contradiction
+		}
+		emit(event)
+
+		// Tile at position could not be collapsed, need to backtrack
+		if s.history.empty() {
+			// Nothing left to undo, every option has been tried
+			return events, s.stop(ErrUnsatisfiable)
+		}
+
+		if s.opts.MaxBacktracks > 0 && s.backtracks >= s.opts.MaxBacktracks {
+			return events, s.stop(ErrMaxBacktracks)
+		}
+		s.backtracks++
+
+		start = time.Now()
+		prevTile := s.history.pop()
+		// We now know the ID for the previous tile was invalid, so we'll remove it as an option
+		prevTileId := s.grid.getTileId(prevTile.pos)
+		for idx, otc := range prevTile.oldTileConfig {
+			if otc.Id == prevTileId.Id {
+				prevTile.oldTileConfig[idx] = prevTile.oldTileConfig[len(prevTile.oldTileConfig)-1]
+				prevTile.oldTileConfig = prevTile.oldTileConfig[:len(prevTile.oldTileConfig)-1]
+				break
+			}
+		}
+
+		// Now update grid to state prior to collapse
+		s.grid.updateTileConfig(prevTile.pos, prevTile.oldTileConfig)
+		revertNeighbourFunc := func(tileConf []Tile, pos position) {
+			if tileConf != nil {
+				s.grid.updateTileConfig(pos, tileConf)
+			}
+		}
+		revertNeighbourFunc(prevTile.oldNeighbours[UP], position{prevTile.pos.x, prevTile.pos.y + 1})
+		revertNeighbourFunc(prevTile.oldNeighbours[RIGHT], position{prevTile.pos.x + 1, prevTile.pos.y})
+		revertNeighbourFunc(prevTile.oldNeighbours[DOWN], position{prevTile.pos.x, prevTile.pos.y - 1})
+		revertNeighbourFunc(prevTile.oldNeighbours[LEFT], position{prevTile.pos.x - 1, prevTile.pos.y})
+		s.stats.PropagationTime += time.Since(start)
+
+		emit(Event{
+			Type:   EventBacktrack,
+			X:      prevTile.pos.x,
+			Y:      prevTile.pos.y,
+			TileId: prevTileId.Id,
+			Domain: tileIds(prevTile.oldTileConfig),
+		})
+
+		// Now collapse the tile that was reverted
+		s.pos = prevTile.pos
+		return events, nil
+	}
+
+	emit(Event{Type: EventCellCollapsed, X: pos.x, Y: pos.y, TileId: s.grid.getTileId(pos).Id})
+	reportNeighbourFunc := func(oldConf []Tile, pos position) {
+		newConf := s.grid.getTileConfig(pos)
+		if oldConf != nil && len(newConf) < len(oldConf) {
+			s.stats.Propagations++
+			s.stats.Eliminated += len(oldConf) - len(newConf)
+			emit(Event{Type: EventDomainReduced, X: pos.x, Y: pos.y, Domain: tileIds(newConf)})
+		}
+	}
+	reportNeighbourFunc(neighbours[UP], position{pos.x, pos.y + 1})
+	reportNeighbourFunc(neighbours[RIGHT], position{pos.x + 1, pos.y})
+	reportNeighbourFunc(neighbours[DOWN], position{pos.x, pos.y - 1})
+	reportNeighbourFunc(neighbours[LEFT], position{pos.x - 1, pos.y})
+
+	trackedTile := oldTile{
+		pos,
+		currTileConf,
+		neighbours,
+	}
+	s.history.push(trackedTile)
+	if s.history.pointer > s.stats.MaxDepth {
+		s.stats.MaxDepth = s.history.pointer
+	}
+
+	// If returns nil, means no tiles left to collapse, so we're done
+	start = time.Now()
+	nextPos := s.grid.tileWithLowestEntropy()
+	s.stats.SelectionTime += time.Since(start)
+	if nextPos == nil {
+		s.finished = true
+	} else {
+		s.pos = *nextPos
+	}
+
+	return events, nil
+}
+
+// Steps until every position is collapsed, the context is done or a limit is reached
+// If generation stops early, returns the partial grid and a *StopError
+// With opts.Checkpoint set, checkpoints every opts.CheckpointEvery and when the context stops the run, so the work can be resumed
+func (s *Solver) Run(ctx context.Context) ([][]int, error) {
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+
+	lastCheckpoint := time.Now()
+	for !s.Done() {
+		if err := ctx.Err(); err != nil {
+			stopErr := &StopError{Reason: err, Steps: s.steps, Backtracks: s.backtracks}
+			if s.opts.Checkpoint != nil {
+				if err := s.opts.Checkpoint(s.Snapshot()); err != nil {
+					return s.Result(), fmt.Errorf("%v, and checkpoint failed: %w", stopErr, err)
+				}
+			}
+			return s.Result(), stopErr
+		}
+
+		if s.opts.Checkpoint != nil && s.opts.CheckpointEvery > 0 && time.Since(lastCheckpoint) >= s.opts.CheckpointEvery {
+			if err := s.opts.Checkpoint(s.Snapshot()); err != nil {
+				return s.Result(), fmt.Errorf("checkpoint failed: %w", err)
+			}
+			lastCheckpoint = time.Now()
+		}
+
+		if _, err := s.Step(); err != nil {
+			return s.Result(), err
+		}
+	}
+
+	return s.Result(), s.err
+}
+
+// Reports if the solver has collapsed every position or been stopped by a limit
+func (s *Solver) Done() bool {
+	return s.finished || s.err != nil
+}
+
+// Returns the error that stopped the solver, nil if it's still running or finished successfully
+func (s *Solver) Err() error {
+	return s.err
+}
+
+// Returns the grid of tile IDs, positions that haven't been collapsed yet are set to Undecided
+func (s *Solver) Result() [][]int {
+	return s.grid.getPartialTileIds()
+}
+
+// Returns the IDs of the tiles still possible at a position
+// A collapsed position returns just its tile, nil if the position is out of range
+func (s *Solver) Domain(x, y int) []int {
+	pos := position{x, y}
+	if tile := s.grid.getTileId(pos); tile != nil {
+		return []int{tile.Id}
+	}
+
+	return tileIds(s.grid.getTileConfig(pos))
+}
+
+// Reports if the position has been collapsed to a single tile
+func (s *Solver) Collapsed(x, y int) bool {
+	return s.grid.getTileId(position{x, y}) != nil
+}
+
+// Returns the width and height of the grid being collapsed
+func (s *Solver) Size() (width, height int) {
+	return len(s.grid.tileConfigurations), len(s.grid.tileConfigurations[0])
+}
+
+// Returns the seed of every random choice the solver makes, passing it in the options reproduces the result
+func (s *Solver) Seed() int64 {
+	return s.seed
+}
+
+// Returns the number of steps taken so far
+func (s *Solver) Steps() int {
+	return s.steps
+}
+
+// Returns the number of times the solver has backtracked so far
+func (s *Solver) Backtracks() int {
+	return s.backtracks
+}
+
+// Marks the solver as stopped and returns the error describing why
+func (s *Solver) stop(reason error) error {
+	s.err = &StopError{Reason: reason, Steps: s.steps, Backtracks: s.backtracks}
+	return s.err
+}
+
+// Returns the IDs of the given tiles, nil if there are no tiles
+func tileIds(tiles []Tile) []int {
+	if tiles == nil {
+		return nil
+	}
+
+	ids := make([]int, len(tiles))
+	for idx, tile := range tiles {
+		ids[idx] = tile.Id
+	}
+	return ids
+}
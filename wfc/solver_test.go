@@ -0,0 +1,106 @@
+package wfc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_Solver_Step(t *testing.T) {
+	width, height := 4, 3
+	solver := NewSolver(generateTileSet(3), width, height, Options{})
+
+	observed := make(map[EventType]int)
+	solver.AddObserver(ObserverFunc(func(event Event) {
+		observed[event.Type]++
+	}))
+
+	for !solver.Done() {
+		events, err := solver.Step()
+		if err != nil {
+			t.Fatalf("Failed, expected no error, got %v", err)
+		}
+
+		if len(events) == 0 {
+			t.Fatalf("Failed, expected step to report events")
+		}
+	}
+
+	if observed[EventCellCollapsed] != width*height {
+		t.Errorf("Failed, expected %v collapsed events, got %v", width*height, observed[EventCellCollapsed])
+	}
+
+	if solver.Steps() != width*height {
+		t.Errorf("Failed, expected %v steps, got %v", width*height, solver.Steps())
+	}
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			if !solver.Collapsed(x, y) {
+				t.Errorf("Failed, expected position %v,%v to be collapsed", x, y)
+			}
+		}
+	}
+}
+
+func Test_Solver_DomainReduced(t *testing.T) {
+	// Tile 1 only allows tile 2 below it and vice versa, so collapsing reduces the neighbours
+	tileSet := []Tile{
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "BBB"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}},
+	}
+	solver := NewSolver(tileSet, 1, 2, Options{})
+
+	events, err := solver.Step()
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if len(events) != 2 || events[0].Type != EventCellCollapsed || events[1].Type != EventDomainReduced {
+		t.Fatalf("Failed, expected collapse then domain reduced, got %v", events)
+	}
+
+	if len(events[1].Domain) != 1 {
+		t.Errorf("Failed, expected %v remaining tile, got %v", 1, events[1].Domain)
+	}
+}
+
+func Test_Solver_Seed(t *testing.T) {
+	tiles := generateTileSet(5)
+	first, err := NewSolver(tiles, 8, 8, Options{Seed: 42}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	second, err := NewSolver(tiles, 8, 8, Options{Seed: 42}).Run(context.Background())
+	if err != nil || !reflect.DeepEqual(first, second) {
+		t.Errorf("Failed, expected the same seed to give the same result, got %v and %v with err %v", first, second, err)
+	}
+
+	if solver := NewSolver(tiles, 8, 8, Options{}); solver.Seed() == 0 {
+		t.Errorf("Failed, expected a seed to be picked")
+	}
+}
+
+func Test_Solver_Constraints(t *testing.T) {
+	tiles := generateTileSet(3)
+	constraints := []Constraint{
+		{X: 0, Y: 0, Tiles: []int{2}},
+		{X: 3, Y: 2, Tiles: []int{0, 1}},
+	}
+
+	res, err := NewSolver(tiles, 4, 3, Options{Constraints: constraints}).Run(context.Background())
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	if res[0][0] != 2 || res[3][2] == 2 {
+		t.Errorf("Failed, expected constrained positions to keep to their tiles, got %v", res)
+	}
+
+	_, err = NewSolver(tiles, 4, 3, Options{Constraints: []Constraint{{X: 1, Y: 1}}}).Run(context.Background())
+	if !errors.Is(err, ErrUnsatisfiable) {
+		t.Errorf("Failed, expected %v for a position with no tiles allowed, got %v", ErrUnsatisfiable, err)
+	}
+}
@@ -39,6 +39,11 @@ func (stack *tileStack) pop() oldTile {
 	return stack.stackSlice[stack.pointer]
 }
 
+// empty reports if there are no tile values left on the stack
+func (stack *tileStack) empty() bool {
+	return stack.pointer == 0
+}
+
 // Tracks an old tile, takes it's position, it's possible tiles, and the neighbours possible tiles
 type oldTile struct {
 	pos           position       // the position of the tile in the grid
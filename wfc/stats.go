@@ -0,0 +1,49 @@
+package wfc
+
+import (
+	"context"
+	"time"
+)
+
+// Stats measures the work a solver has done, to compare tilesets and settings
+// A resumed solver carries on counting steps and backtracks from its snapshot, everything else starts again
+type Stats struct {
+	Steps           int           `json:"steps"`
+	Backtracks      int           `json:"backtracks"`
+	Contradictions  int           `json:"contradictions"`  // steps where the position had no tile that fitted its neighbours
+	MaxDepth        int           `json:"maxDepth"`        // most collapsed positions the solver could backtrack through at once
+	Propagations    int           `json:"propagations"`    // neighbour domains narrowed by collapsing a position
+	Eliminated      int           `json:"eliminated"`      // tiles removed from neighbour domains by those propagations
+	SelectionTime   time.Duration `json:"selectionTime"`   // time spent picking the next position to collapse
+	PropagationTime time.Duration `json:"propagationTime"` // time spent collapsing positions, narrowing their neighbours and backtracking
+	TileUsage       map[int]int   `json:"tileUsage"`       // collapsed positions using each tile ID, every tile in the tileset included
+}
+
+// Runs Solve and returns the statistics of the solver that produced the result alongside it
+// The statistics are empty if the grid can't be solved at all, see CheckGrid
+func CollapseStats(ctx context.Context, tiles []Tile, width, height int, opts Options) ([][]int, Stats, error) {
+	solver, res, err := Solve(ctx, tiles, width, height, opts)
+	if solver == nil {
+		return res, Stats{}, err
+	}
+	return res, solver.Stats(), err
+}
+
+// Returns the statistics of the work done so far, tile usage counts the positions collapsed right now
+func (s *Solver) Stats() Stats {
+	stats := s.stats
+	stats.Steps = s.steps
+	stats.Backtracks = s.backtracks
+	stats.TileUsage = make(map[int]int, len(s.tiles))
+	for _, tile := range s.tiles {
+		stats.TileUsage[tile.Id] = 0
+	}
+	for _, column := range s.grid.getPartialTileIds() {
+		for _, id := range column {
+			if id != Undecided {
+				stats.TileUsage[id]++
+			}
+		}
+	}
+	return stats
+}
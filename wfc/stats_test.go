@@ -0,0 +1,62 @@
+package wfc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_Solver_Stats(t *testing.T) {
+	width, height := 8, 8
+	solver := NewSolver(cornerTileSet(), width, height, Options{Seed: 8})
+
+	reduced := 0
+	solver.AddObserver(ObserverFunc(func(event Event) {
+		if event.Type == EventDomainReduced {
+			reduced++
+		}
+	}))
+	if _, err := solver.Run(context.Background()); err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	stats := solver.Stats()
+	if stats.Steps != solver.Steps() || stats.Backtracks != solver.Backtracks() || stats.Backtracks == 0 {
+		t.Errorf("Failed, expected %v steps and %v backtracks, got %+v", solver.Steps(), solver.Backtracks(), stats)
+	}
+	if stats.Contradictions != stats.Backtracks {
+		t.Errorf("Failed, expected a contradiction for every backtrack, got %v and %v", stats.Contradictions, stats.Backtracks)
+	}
+	// Every position stays on the history once the grid is finished
+	if stats.MaxDepth != width*height {
+		t.Errorf("Failed, expected max depth %v, got %v", width*height, stats.MaxDepth)
+	}
+	if stats.Propagations != reduced || stats.Eliminated < stats.Propagations {
+		t.Errorf("Failed, expected %v propagations eliminating at least as many tiles, got %v and %v", reduced, stats.Propagations, stats.Eliminated)
+	}
+	if stats.SelectionTime <= 0 || stats.PropagationTime <= 0 {
+		t.Errorf("Failed, expected time spent selecting and propagating, got %v and %v", stats.SelectionTime, stats.PropagationTime)
+	}
+
+	used := 0
+	for _, count := range stats.TileUsage {
+		used += count
+	}
+	if len(stats.TileUsage) != len(cornerTileSet()) || used != width*height {
+		t.Errorf("Failed, expected usage of %v tiles adding up to %v, got %v", len(cornerTileSet()), width*height, stats.TileUsage)
+	}
+}
+
+func Test_CollapseStats(t *testing.T) {
+	opts := Options{Seed: 8}
+	res, stats, err := CollapseStats(context.Background(), cornerTileSet(), 8, 8, opts)
+	if err != nil {
+		t.Fatalf("Failed, expected no error, got %v", err)
+	}
+
+	solver := NewSolver(cornerTileSet(), 8, 8, opts)
+	expected, _ := solver.Run(context.Background())
+	if !reflect.DeepEqual(expected, res) || stats.Steps != solver.Steps() || !reflect.DeepEqual(solver.Stats().TileUsage, stats.TileUsage) {
+		t.Errorf("Failed, expected the same result and stats as running a solver, got %+v", stats)
+	}
+}
@@ -9,6 +9,7 @@ import (
 type tileGrid struct {
 	tileConfigurations [][][]Tile // tracks the possible tiles in a given position
 	positionsCollapsed [][]bool   // tracks the positions that have been collapsed
+	rng                *rand.Rand // makes every random choice, seeded by the solver
 }
 
 // Returns a new tileGrid to the given width, height and use the tileset's IDs to track the tiles
@@ -31,7 +32,27 @@ func newTileGrid(width, height int, tileset []Tile) tileGrid {
 		}
 	}
 
-	return tileGrid{tileConfigurations: tiles, positionsCollapsed: grid}
+	return tileGrid{tileConfigurations: tiles, positionsCollapsed: grid, rng: rand.New(rand.NewSource(rand.Int63()))}
+}
+
+// Limits the tiles possible at a position to the given IDs
+func (tg tileGrid) constrain(pos position, ids []int) {
+	if pos.x < 0 || pos.x >= len(tg.tileConfigurations) || pos.y < 0 || pos.y >= len(tg.tileConfigurations[0]) {
+		panic(fmt.Errorf("constraint at pos %v is outside the grid", pos))
+	}
+
+	allowed := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+
+	tiles := make([]Tile, 0, len(ids))
+	for _, tile := range tg.tileConfigurations[pos.x][pos.y] {
+		if allowed[tile.Id] {
+			tiles = append(tiles, tile)
+		}
+	}
+	tg.tileConfigurations[pos.x][pos.y] = tiles
 }
 
 // Selects a random valid tile at the given position and update relevant neighbours
@@ -43,10 +64,12 @@ func (tg tileGrid) collapseTile(pos position) bool {
 		panic(fmt.Errorf("attempt to collapse already collapsed tile at pos %v", pos))
 	}
 
-	possibleTiles := tg.tileConfigurations[pos.x][pos.y]
+	// Copy the options, as rejected tiles are removed from them and the slice can be shared with other positions
+	possibleTiles := make([]Tile, len(tg.tileConfigurations[pos.x][pos.y]))
+	copy(possibleTiles, tg.tileConfigurations[pos.x][pos.y])
 	for len(possibleTiles) > 0 {
-		// Select random tile from the possible tiles
-		selectedTileIdx := rand.Intn(len(possibleTiles))
+		// Select random tile from the possible tiles, favouring heavier tiles
+		selectedTileIdx := weightedIndex(tg.rng, possibleTiles)
 		selectedTile := possibleTiles[selectedTileIdx]
 
 		// Tile is invalid if it makes any of its neighbours invalid, so need to check neighbours
@@ -160,7 +183,7 @@ func (tg tileGrid) tileWithLowestEntropy() *position {
 		}
 	}
 
-	res := possiblePos[rand.Intn(len(possiblePos))]
+	res := possiblePos[tg.rng.Intn(len(possiblePos))]
 	return &res
 }
 
@@ -181,6 +204,24 @@ func (tg tileGrid) getTileIds() [][]int {
 	return tileIds
 }
 
+// Returns a grid of IDs like getTileIds, but positions that haven't been collapsed are set to Undecided
+// Used to return a partial result when generation stops early
+func (tg tileGrid) getPartialTileIds() [][]int {
+	tileIds := make([][]int, len(tg.tileConfigurations))
+	for row := range tg.tileConfigurations {
+		tileIds[row] = make([]int, len(tg.tileConfigurations[row]))
+		for col, tile := range tg.tileConfigurations[row] {
+			if !tg.positionsCollapsed[row][col] {
+				tileIds[row][col] = Undecided
+				continue
+			}
+
+			tileIds[row][col] = tile[0].Id
+		}
+	}
+	return tileIds
+}
+
 // Returns the possible tile configurations at a position
 // Nil if position is out of range, or position has already been collapsed
 func (tg tileGrid) getTileConfig(pos position) []Tile {
@@ -220,6 +261,7 @@ func (tg tileGrid) getTileId(pos position) *Tile {
 	res := Tile{
 		tile.Id,
 		tile.Configuration,
+		tile.Weight,
 	}
 
 	return &res
@@ -239,3 +281,21 @@ func (tg tileGrid) updateTileConfig(pos position, tileConfig []Tile) {
 	tg.positionsCollapsed[pos.x][pos.y] = false
 	tg.tileConfigurations[pos.x][pos.y] = tileConfig
 }
+
+// Returns a random index into tiles, each tile's chance of being picked is proportional to its weight
+func weightedIndex(rng *rand.Rand, tiles []Tile) int {
+	total := 0.0
+	for _, tile := range tiles {
+		total += tile.weight()
+	}
+
+	target := rng.Float64() * total
+	for idx, tile := range tiles {
+		target -= tile.weight()
+		if target < 0 {
+			return idx
+		}
+	}
+
+	return len(tiles) - 1
+}
@@ -1,77 +1,26 @@
 package wfc
 
 import (
-	"math/rand"
+	"context"
 )
 
 // Exposed function to run the collapse algorithm against a tileset
-// Mainly responsible for orchestrating interal structures to run the algorithm
+// Panics if generation fails, use CollapseContext to handle failures and limit how long it runs
 func Collapse(tiles []Tile, width int, height int) [][]int {
-	tileGrid := newTileGrid(width, height, tiles)
-	positionTracker := tileStack{}
-
-	pos := position{
-		x: rand.Intn(width),
-		y: rand.Intn(height),
+	res, err := CollapseContext(context.Background(), tiles, width, height, Options{})
+	if err != nil {
+		panic(err)
 	}
-	finished := false
-	for !finished {
-		currTileConf := tileGrid.getTileConfig(pos)
-		// Track neighbours before tiles are collapsed incase we need to backtrack
-		neighbours := make(map[int][]Tile)
-		neighbours[UP] = tileGrid.getTileConfig(position{pos.x, pos.y + 1})
-		neighbours[RIGHT] = tileGrid.getTileConfig(position{pos.x + 1, pos.y})
-		neighbours[DOWN] = tileGrid.getTileConfig(position{pos.x, pos.y - 1})
-		neighbours[LEFT] = tileGrid.getTileConfig(position{pos.x - 1, pos.y})
-
-		collapsedTile := tileGrid.collapseTile(pos)
-		if !collapsedTile {
-			// Tile at position could not be collapsed, need to backtrack
-			prevTile := positionTracker.pop()
-			// We now know the ID for the previous tile was invalid, so we'll remove it as an option
-			prevTileId := tileGrid.getTileId(prevTile.pos)
-			for idx, otc := range prevTile.oldTileConfig {
-				if otc.Id == prevTileId.Id {
-					prevTile.oldTileConfig[idx] = prevTile.oldTileConfig[len(prevTile.oldTileConfig)-1]
-					prevTile.oldTileConfig = prevTile.oldTileConfig[:len(prevTile.oldTileConfig)-1]
-					break
-				}
-			}
-
-			// Now update grid to state prior to collapse
-			tileGrid.updateTileConfig(prevTile.pos, prevTile.oldTileConfig)
-			revertNeighbourFunc := func(tileConf []Tile, pos position) {
-				if tileConf != nil {
-					tileGrid.updateTileConfig(pos, tileConf)
-				}
-			}
-			revertNeighbourFunc(prevTile.oldNeighbours[UP], position{prevTile.pos.x, prevTile.pos.y + 1})
-			revertNeighbourFunc(prevTile.oldNeighbours[RIGHT], position{prevTile.pos.x + 1, prevTile.pos.y})
-			revertNeighbourFunc(prevTile.oldNeighbours[DOWN], position{prevTile.pos.x, prevTile.pos.y - 1})
-			revertNeighbourFunc(prevTile.oldNeighbours[LEFT], position{prevTile.pos.x - 1, prevTile.pos.y})
 
-			// Now collapse the tile that was reverted
-			pos = prevTile.pos
-			continue
-		}
-
-		trackedTile := oldTile{
-			pos,
-			currTileConf,
-			neighbours,
-		}
-		positionTracker.push(trackedTile)
-
-		// If returns nil, means no tiles left to collapse, so we're done
-		nextPos := tileGrid.tileWithLowestEntropy()
-		if nextPos == nil {
-			finished = true
-		} else {
-			pos = *nextPos
-		}
-	}
+	return res
+}
 
-	return tileGrid.getTileIds()
+// Runs the collapse algorithm against a tileset, stopping early if the context is done or a limit in opts is reached
+// If generation stops early, returns the partial grid (undecided positions set to Undecided) and a *StopError
+// Runs opts.Attempts solvers in parallel if set, see Solve. Returns CheckGrid's error without generating if the grid can't be solved
+func CollapseContext(ctx context.Context, tiles []Tile, width int, height int, opts Options) ([][]int, error) {
+	_, res, err := Solve(ctx, tiles, width, height, opts)
+	return res, err
 }
 
 const (
@@ -86,8 +35,22 @@ type position struct {
 }
 
 type Tile struct {
-	Id            int
-	Configuration map[int]string // left, up, right, down
+	Id            int            `json:"id"`
+	Configuration map[int]string `json:"configuration"`    // left, up, right, down
+	Weight        float64        `json:"weight,omitempty"` // how likely the tile is to be picked relative to others, 0 is treated as 1
+}
+
+// Returns the weight used when picking the tile, unset weights count as 1
+func (tile Tile) weight() float64 {
+	if tile.Weight <= 0 {
+		return 1
+	}
+	return tile.Weight
+}
+
+// Reports if tile2 can sit next to tile1 in the given direction
+func Matches(dir int, tile1, tile2 Tile) bool {
+	return match(dir, tile1, tile2)
 }
 
 func match(dir int, tile1, tile2 Tile) bool {
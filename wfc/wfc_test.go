@@ -1,6 +1,9 @@
 package wfc
 
 import (
+	"context"
+	"errors"
+	"math/rand"
 	"reflect"
 	"testing"
 )
@@ -14,10 +17,10 @@ func Test_Collapse_Simple(t *testing.T) {
 		{
 			"Small grid, two tile",
 			[]Tile{
-				{1, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
-				{2, map[int]string{LEFT: "BBB", UP: "AAA", RIGHT: "BBB", DOWN: "AAA"}},
-				{3, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "BBB", DOWN: "AAA"}},
-				{4, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "BBB"}},
+				{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+				{Id: 2, Configuration: map[int]string{LEFT: "BBB", UP: "AAA", RIGHT: "BBB", DOWN: "AAA"}},
+				{Id: 3, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "BBB", DOWN: "AAA"}},
+				{Id: 4, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "BBB"}},
 			},
 			10, 10,
 		},
@@ -32,10 +35,62 @@ func Test_Collapse_Simple(t *testing.T) {
 
 }
 
+func Test_CollapseContext_Unsatisfiable(t *testing.T) {
+	// No tile can sit next to another horizontally
+	tileSet := []Tile{
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "AAA", UP: "CCC", RIGHT: "BBB", DOWN: "CCC"}},
+	}
+
+	_, err := CollapseContext(context.Background(), tileSet, 2, 2, Options{})
+	if !errors.Is(err, ErrUnsatisfiable) {
+		t.Errorf("Failed, expected %v, got %v", ErrUnsatisfiable, err)
+	}
+}
+
+func Test_CollapseContext_MaxSteps(t *testing.T) {
+	res, err := CollapseContext(context.Background(), generateTileSet(2), 10, 10, Options{MaxSteps: 5})
+	if !errors.Is(err, ErrMaxSteps) {
+		t.Fatalf("Failed, expected %v, got %v", ErrMaxSteps, err)
+	}
+
+	var stopErr *StopError
+	if !errors.As(err, &stopErr) || stopErr.Steps != 5 {
+		t.Errorf("Failed, expected StopError with %v steps, got %v", 5, err)
+	}
+
+	decided := 0
+	for row := range res {
+		for col := range res[row] {
+			if res[row][col] != Undecided {
+				decided++
+			}
+		}
+	}
+
+	if decided != 5 {
+		t.Errorf("Failed, expected %v decided positions, got %v", 5, decided)
+	}
+}
+
+func Test_CollapseContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := CollapseContext(ctx, generateTileSet(2), 4, 4, Options{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Failed, expected %v, got %v", context.Canceled, err)
+	}
+
+	if res[0][0] != Undecided {
+		t.Errorf("Failed, expected %v, got %v", Undecided, res[0][0])
+	}
+}
+
 func Test_tileGrid_tileWithLowestEntropy(t *testing.T) {
 	tg := newTileGrid(2, 2, []Tile{
-		{1, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
-		{2, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
 	})
 
 	tg.tileConfigurations[1][1] = []Tile{}
@@ -50,8 +105,8 @@ func Test_tileGrid_tileWithLowestEntropy(t *testing.T) {
 
 func Test_tileGrid_collapseTile_success(t *testing.T) {
 	tg := newTileGrid(2, 2, []Tile{
-		{1, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
-		{2, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+		{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+		{Id: 2, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
 	})
 
 	pos := position{0, 0}
@@ -65,15 +120,15 @@ func Test_tileGrid_collapseTile_success(t *testing.T) {
 		t.Errorf("Failed, expected %v, got %v", true, success)
 	}
 
-	expected := Tile{1, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}}
+	expected := Tile{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}}
 	if !reflect.DeepEqual(expected, tg.tileConfigurations[pos.x][pos.y][0]) {
 		t.Errorf("Failed, expected %v, got %v", expected, tg.tileConfigurations[pos.x][pos.y])
 	}
 }
 
 func Test_tileGrid_collapseTile_neighboursUpdate(t *testing.T) {
-	tile1 := Tile{1, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "BBB"}}
-	tile2 := Tile{1, map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}}
+	tile1 := Tile{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "BBB"}}
+	tile2 := Tile{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}}
 	tg := newTileGrid(2, 2, []Tile{
 		tile1,
 		tile2,
@@ -116,64 +171,64 @@ func Test_match(t *testing.T) {
 		{
 			"Empty tiles, should match",
 			UP,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
-			Tile{1, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 1, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
 			true,
 		},
 		{
 			"Tile with up and tile with matching down, should match",
 			UP,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}},
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "BBB"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "BBB"}},
 			true,
 		},
 		{
 			"Tile with up and tile without matching down, should not match",
 			UP,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}},
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
 			false,
 		},
 		{
 			"Tile with up and tile without matching down, should not match",
 			UP,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}},
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "CCC"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "BBB", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "CCC"}},
 			false,
 		},
 		{
 			"Full tiles, up, should match",
 			UP,
-			Tile{0, map[int]string{LEFT: "BBB", UP: "BBB", RIGHT: "BBB", DOWN: "BBB"}},
-			Tile{0, map[int]string{LEFT: "BBB", UP: "BBB", RIGHT: "BBB", DOWN: "BBB"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "BBB", UP: "BBB", RIGHT: "BBB", DOWN: "BBB"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "BBB", UP: "BBB", RIGHT: "BBB", DOWN: "BBB"}},
 			true,
 		},
 		{
 			"Full tiles, left, should match",
 			LEFT,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
 			true,
 		},
 		{
 			"Full tiles, right, should match",
 			RIGHT,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
 			true,
 		},
 		{
 			"Full tiles, down, should match",
 			DOWN,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAA"}},
 			true,
 		},
 		{
 			"Assymetric tiles, down, should match",
 			DOWN,
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAB"}},
-			Tile{0, map[int]string{LEFT: "AAA", UP: "AAB", RIGHT: "AAA", DOWN: "AAA"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAA", RIGHT: "AAA", DOWN: "AAB"}},
+			Tile{Id: 0, Configuration: map[int]string{LEFT: "AAA", UP: "AAB", RIGHT: "AAA", DOWN: "AAA"}},
 			true,
 		},
 	}
@@ -187,3 +242,21 @@ func Test_match(t *testing.T) {
 		})
 	}
 }
+
+func Test_weightedIndex(t *testing.T) {
+	tiles := []Tile{
+		{Id: 0, Weight: 1},
+		{Id: 1, Weight: 1e9},
+		{Id: 2},
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	picks := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		picks[weightedIndex(rng, tiles)]++
+	}
+
+	if picks[1] < 99 {
+		t.Errorf("Failed, expected the heaviest tile to be picked almost every time, got %v", picks)
+	}
+}